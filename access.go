@@ -18,6 +18,8 @@ import (
 	"errors"
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"unsafe"
 
 	"github.com/henrylee2cn/ameda"
 )
@@ -25,11 +27,39 @@ import (
 type (
 	// Accessor struct accessor factory
 	Accessor struct {
-		dict     map[int32]*StructType // key is runtime type ID
-		rw       sync.RWMutex
-		groupBy  GroupByFunc
-		iterator IteratorFunc
-		maxDeep  int
+		// the 64-bit atomic counters are placed first to be aligned on 32-bit platforms
+		clock        uint64 // the clock of the cache uses
+		misses       uint64
+		evictions    uint64
+		hits         *stripedCounter
+		dict         atomic.Value // map[int32]*cacheEntry, key is runtime type ID, copy on write
+		mu           sync.Mutex   // serialize the writers of dict and pending
+		pending      map[int32]*cacheCall
+		gen          uint64 // guarded by mu, changed by Forget and Purge to discard the in-flight analyses
+		cacheSize    int
+		groupBy      GroupByFunc
+		iterator     IteratorFunc
+		visibility   Visibility
+		maxDeep      int
+		descendIface bool
+		lazy         bool
+		tagKeys      []string
+	}
+	cacheEntry struct {
+		used uint64 // the clock of the last use, maintained only if the cache size is limited
+		sTyp *StructType
+	}
+	// cacheCall the in-flight analysis of a struct type
+	cacheCall struct {
+		wg   sync.WaitGroup
+		sTyp *StructType
+	}
+	// CacheStats the statistics of the struct type cache of Accessor
+	CacheStats struct {
+		Size      int
+		Hits      uint64
+		Misses    uint64
+		Evictions uint64
 	}
 )
 
@@ -40,17 +70,24 @@ var (
 	zero            = reflect.Value{}
 	errTypeMismatch = errors.New("type mismatch")
 	errIllegalType  = errors.New("type is not struct pointer")
+	errIllegalID    = errors.New("field id out of range")
+	errNotStruct    = errors.New("field is not struct")
+	errNotContainer = errors.New("field is not a container of struct")
+	errNotAddrElem  = errors.New("map element is not addressable")
+	errNotDynStruct = errors.New("interface does not hold a struct pointer")
 )
 
 // New create a new struct accessor factory.
 func New(opt ...Option) *Accessor {
 	a := &Accessor{
-		dict:    make(map[int32]*StructType, 1024),
+		hits:    newStripedCounter(),
+		pending: make(map[int32]*cacheCall),
 		maxDeep: 16,
 	}
 	for _, fn := range opt {
 		fn(a)
 	}
+	a.dict.Store(map[int32]*cacheEntry{})
 	return a
 }
 
@@ -98,13 +135,50 @@ func (a *Accessor) Analyze(structPtr interface{}) (*StructType, error) {
 	return a.analyze(tid, structPtr), nil
 }
 
+// analyze return the cached struct type info, or analyze it.
+// NOTE:
+//  The concurrent callers of a new type share one analysis
 func (a *Accessor) analyze(tid int32, structPtr interface{}) *StructType {
-	sTyp, ok := a.load(tid)
-	if !ok {
-		sTyp = newStructType(a, tid, structPtr)
-		a.store(sTyp)
+	if sTyp, ok := a.load(tid); ok {
+		return sTyp
+	}
+	a.mu.Lock()
+	// double check, the type may be stored after the load
+	if e, ok := a.loadDict()[tid]; ok {
+		a.mu.Unlock()
+		return e.sTyp
 	}
-	return sTyp
+	if c, ok := a.pending[tid]; ok {
+		a.mu.Unlock()
+		c.wg.Wait()
+		if c.sTyp == nil {
+			// the analysis panicked
+			return a.analyze(tid, structPtr)
+		}
+		return c.sTyp
+	}
+	c := new(cacheCall)
+	c.wg.Add(1)
+	a.pending[tid] = c
+	gen := a.gen
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		// the analysis started before Forget or Purge is not cached
+		if c.sTyp != nil && gen == a.gen {
+			a.store(c.sTyp)
+		}
+		delete(a.pending, tid)
+		a.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.sTyp = newStructType(a, tid, structPtr)
+	return c.sTyp
+}
+
+// analyzeType analyze the struct type and return its type info.
+func (a *Accessor) analyzeType(structTyp reflect.Type) *StructType {
+	return a.analyze(ameda.RuntimeTypeID(structTyp), reflect.New(structTyp))
 }
 
 // MustAccess analyze the struct type info and create struct accessor.
@@ -112,12 +186,7 @@ func (a *Accessor) analyze(tid int32, structPtr interface{}) *StructType {
 //  If structPtr is not a struct pointer, it will cause panic.
 func (a *Accessor) MustAccess(structPtr interface{}) *Struct {
 	tid, ptr := parseStructInfo(structPtr)
-	sTyp, ok := a.load(tid)
-	if !ok {
-		sTyp = newStructType(a, tid, structPtr)
-		a.store(sTyp)
-	}
-	return newStruct(sTyp, ptr)
+	return newStruct(a.analyze(tid, structPtr), ptr)
 }
 
 // Access analyze the struct type info and create struct accessor.
@@ -126,53 +195,144 @@ func (a *Accessor) Access(structPtr interface{}) (*Struct, error) {
 	if err != nil {
 		return nil, err
 	}
-	sTyp, ok := a.load(tid)
-	if !ok {
-		sTyp = newStructType(a, tid, structPtr)
-		a.store(sTyp)
-	}
-	return newStruct(sTyp, ptr), nil
+	return newStruct(a.analyze(tid, structPtr), ptr), nil
 }
 
+func (a *Accessor) loadDict() map[int32]*cacheEntry {
+	return a.dict.Load().(map[int32]*cacheEntry)
+}
+
+// load read the cache without any lock.
+// NOTE:
+//  The hits are counted by a striped counter, so the unlimited cache has no shared write on the hit path;
+//  The limited cache updates the recency clock on every hit
 func (a *Accessor) load(tid int32) (*StructType, bool) {
-	a.rw.RLock()
-	sTyp, ok := a.dict[tid]
-	a.rw.RUnlock()
-	return sTyp, ok
+	e, ok := a.loadDict()[tid]
+	if !ok {
+		atomic.AddUint64(&a.misses, 1)
+		return nil, false
+	}
+	a.hits.add()
+	if a.cacheSize > 0 {
+		atomic.StoreUint64(&e.used, atomic.AddUint64(&a.clock, 1))
+	}
+	return e.sTyp, true
 }
 
+// store publish a copy of the cache with sTyp added, the caller holds a.mu.
 func (a *Accessor) store(sTyp *StructType) {
-	a.rw.Lock()
-	a.dict[sTyp.tid] = sTyp
-	a.rw.Unlock()
+	e := &cacheEntry{sTyp: sTyp}
+	if a.cacheSize > 0 {
+		e.used = atomic.AddUint64(&a.clock, 1)
+	}
+	old := a.loadDict()
+	dict := make(map[int32]*cacheEntry, len(old)+1)
+	for tid, e := range old {
+		dict[tid] = e
+	}
+	dict[sTyp.tid] = e
+	for a.cacheSize > 0 && len(dict) > a.cacheSize {
+		evictLeastRecent(dict)
+		atomic.AddUint64(&a.evictions, 1)
+	}
+	a.dict.Store(dict)
 }
 
-func parseStructInfo(structPtr interface{}) (int32, uintptr) {
+// evictLeastRecent remove the least recently used struct type from the unpublished dict.
+func evictLeastRecent(dict map[int32]*cacheEntry) {
+	var (
+		oldest int32
+		least  uint64
+		found  bool
+	)
+	for tid, e := range dict {
+		if used := atomic.LoadUint64(&e.used); !found || used < least {
+			oldest, least, found = tid, used, true
+		}
+	}
+	delete(dict, oldest)
+}
+
+// Forget remove the cached info of the struct type, which is the struct type or the struct pointer type,
+// and report whether it was cached.
+// NOTE:
+//  The removed StructType and its accessors are still usable, but no longer shared;
+//  The analyses in flight are not cached when they finish
+func (a *Accessor) Forget(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	// NOTE: *A and A have the same runtime type ID
+	tid := ameda.RuntimeTypeIDOf(reflect.New(typ).Interface())
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.gen++
+	old := a.loadDict()
+	if _, ok := old[tid]; !ok {
+		return false
+	}
+	dict := make(map[int32]*cacheEntry, len(old))
+	for k, e := range old {
+		if k != tid {
+			dict[k] = e
+		}
+	}
+	a.dict.Store(dict)
+	return true
+}
+
+// Purge remove all cached struct types.
+// NOTE:
+//  The analyses in flight are not cached when they finish
+func (a *Accessor) Purge() {
+	a.mu.Lock()
+	a.gen++
+	a.dict.Store(map[int32]*cacheEntry{})
+	a.mu.Unlock()
+}
+
+// Stats return the statistics of the struct type cache.
+func (a *Accessor) Stats() CacheStats {
+	return CacheStats{
+		Size:      len(a.loadDict()),
+		Hits:      a.hits.load(),
+		Misses:    atomic.LoadUint64(&a.misses),
+		Evictions: atomic.LoadUint64(&a.evictions),
+	}
+}
+
+func parseStructInfo(structPtr interface{}) (int32, unsafe.Pointer) {
 	if val, ok := structPtr.(reflect.Value); ok {
-		tid := ameda.RuntimeTypeIDOf(structPtr)
-		ptr := val.Pointer()
-		return tid, ptr
+		tid := ameda.RuntimeTypeID(val.Type())
+		return tid, valuePointer(&val)
 	}
-	val := ameda.ValueOf(structPtr)
-	tid := val.RuntimeTypeID()
-	ptr := val.Pointer()
-	return tid, ptr
+	tid := ameda.RuntimeTypeIDOf(structPtr)
+	return tid, (*emptyInterface)(unsafe.Pointer(&structPtr)).ptr
 }
 
-func parseStructInfoWithCheck(structPtr interface{}) (int32, uintptr, error) {
+func parseStructInfoWithCheck(structPtr interface{}) (int32, unsafe.Pointer, error) {
 	if val, ok := structPtr.(reflect.Value); ok {
 		if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
-			return 0, 0, errIllegalType
+			return 0, nil, errIllegalType
 		}
-		tid := ameda.RuntimeTypeIDOf(structPtr)
-		ptr := val.Pointer()
-		return tid, ptr, nil
+		tid := ameda.RuntimeTypeID(val.Type())
+		return tid, valuePointer(&val), nil
 	}
 	val := ameda.ValueOf(structPtr)
 	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
-		return 0, 0, errIllegalType
+		return 0, nil, errIllegalType
 	}
 	tid := val.RuntimeTypeID()
-	ptr := val.Pointer()
-	return tid, ptr, nil
+	return tid, (*emptyInterface)(unsafe.Pointer(&structPtr)).ptr, nil
+}
+
+// NOTE:
+//  Read the pointer through unsafe.Pointer instead of uintptr,
+//  so that the compiler knows the object escapes to the heap.
+func valuePointer(v *reflect.Value) unsafe.Pointer {
+	rv := (*reflectValue)(unsafe.Pointer(v))
+	if rv.flag&flagIndir != 0 {
+		return *(*unsafe.Pointer)(rv.ptr)
+	}
+	return rv.ptr
 }
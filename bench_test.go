@@ -326,3 +326,196 @@ func BenchmarkTag_Reflect1(b *testing.B) {
 	}
 	b.StopTimer()
 }
+
+// BenchmarkTag_Gofield2 query the names and options of the tags parsed at analysis,
+// against the parsing on every query of BenchmarkTag_Reflect2.
+func BenchmarkTag_Gofield2(b *testing.B) {
+	b.ReportAllocs()
+	st := gofield.New(gofield.WithTagKeys("mapper")).MustAnalyze(&G{})
+	var names []string
+	for _, ft := range st.FieldsWithTag("mapper") {
+		tag, _ := ft.TagValue("mapper")
+		names = append(names, tag.Name)
+	}
+	assert.Equal(b, []string{"a", "b", "c", "d", "e", "e", "e", "e", "e"}, names)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ft := range st.FieldsWithTag("mapper") {
+			tag, _ := ft.TagValue("mapper")
+			if tag.Name == "" || tag.HasOption("omitempty") {
+				b.Fatal("unexpected tag")
+			}
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkTag_Reflect2(b *testing.B) {
+	b.ReportAllocs()
+	t := reflect.TypeOf(G{})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < t.NumField(); j++ {
+			value, ok := t.Field(j).Tag.Lookup("mapper")
+			if !ok {
+				continue
+			}
+			tag := gofield.ParseTagValue(value)
+			if tag.Name == "" || tag.HasOption("omitempty") {
+				b.Fatal("unexpected tag")
+			}
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkSet_Gofield1(b *testing.B) {
+	b.ReportAllocs()
+	var p P1
+	s := gofield.MustAccess(&p)
+	ids := s.Filter(func(t *gofield.FieldType) bool {
+		return t.UnderlyingKind() == reflect.Int
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			s.FieldValue(id).SetInt(int64(id + 1))
+		}
+	}
+	b.StopTimer()
+
+	assert.Equal(b, 1, p.A)
+	assert.Equal(b, 9, **p.g)
+}
+
+func BenchmarkSet_Generic1(b *testing.B) {
+	b.ReportAllocs()
+	var p P1
+	s := gofield.MustAccess(&p)
+	ids := s.Filter(func(t *gofield.FieldType) bool {
+		return t.UnderlyingKind() == reflect.Int
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			_ = gofield.Set(s, id, id+1)
+		}
+	}
+	b.StopTimer()
+
+	assert.Equal(b, 1, p.A)
+	assert.Equal(b, 9, **p.g)
+}
+
+func BenchmarkSet_TypedField1(b *testing.B) {
+	b.ReportAllocs()
+	var p P1
+	s := gofield.MustAccess(&p)
+	ids := s.Filter(func(t *gofield.FieldType) bool {
+		return t.UnderlyingKind() == reflect.Int
+	})
+	fields := make([]gofield.TypedField[int], len(ids))
+	for i, id := range ids {
+		fields[i], _ = gofield.Field[int](s.StructType, id)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, f := range fields {
+			f.Set(s, ids[j]+1)
+		}
+	}
+	b.StopTimer()
+
+	assert.Equal(b, 1, p.A)
+	assert.Equal(b, 9, **p.g)
+}
+
+func BenchmarkNested_Handle1(b *testing.B) {
+	b.ReportAllocs()
+	st := gofield.MustAnalyze(&P1{})
+	ids := st.Filter(func(t *gofield.FieldType) bool {
+		return t.UnderlyingKind() == reflect.Int
+	})
+	handles := make([]*gofield.Handle, len(ids))
+	for i, id := range ids {
+		handles[i], _ = st.Handle(id)
+	}
+	var p P1
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p = P1{}
+		for j, h := range handles {
+			*(*int)(h.Ptr(unsafe.Pointer(&p))) = ids[j] + 1
+		}
+	}
+	b.StopTimer()
+
+	assert.Equal(b, 1, p.A)
+	assert.Equal(b, 2, p.b)
+	assert.Equal(b, 4, p.C)
+	assert.Equal(b, 5, *p.d)
+	assert.Equal(b, 7, p.E)
+	assert.Equal(b, 8, *p.f)
+	assert.Equal(b, 9, **p.g)
+}
+
+func BenchmarkNested_Pool1(b *testing.B) {
+	b.ReportAllocs()
+	st := gofield.MustAnalyze(&P1{})
+	ids := st.Filter(func(t *gofield.FieldType) bool {
+		return t.UnderlyingKind() == reflect.Int
+	})
+	var p P1
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p = P1{}
+		s, _ := st.AcquireAccess(&p)
+		for _, id := range ids {
+			s.FieldValue(id).SetInt(int64(id + 1))
+		}
+		st.ReleaseAccess(s)
+	}
+	b.StopTimer()
+
+	assert.Equal(b, 1, p.A)
+	assert.Equal(b, 9, **p.g)
+}
+
+type WideRoot struct {
+	R1, R2, R3, R4, R5, R6, R7, R8 LazyRoot
+}
+
+func BenchmarkAnalyze_Eager(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var r WideRoot
+		s := gofield.New().MustAccess(&r)
+		v, _ := s.FieldValueBySelector("R1.Sub.B")
+		v.SetInt(1)
+	}
+}
+
+func BenchmarkAnalyze_Lazy(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var r WideRoot
+		s := gofield.New(gofield.WithLazyAnalysis(true)).MustAccess(&r)
+		v, _ := s.FieldValueBySelector("R1.Sub.B")
+		v.SetInt(1)
+	}
+}
+
+func BenchmarkAnalyze_Parallel(b *testing.B) {
+	b.ReportAllocs()
+	acc := gofield.New()
+	acc.MustAnalyze(&P1{})
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var p P1
+		for pb.Next() {
+			acc.MustAnalyze(&p)
+		}
+	})
+}
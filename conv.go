@@ -0,0 +1,278 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// convertFunc assign src to the addressable dst.
+type convertFunc func(dst, src reflect.Value)
+
+// converterOf return the function that converts srcTyp value to dstTyp value.
+// NOTE:
+//  Support identical types, numeric kinds of any width, string and []byte,
+//  and the types with the same underlying kind;
+//  Return false if the types are not convertible
+func converterOf(dstTyp, srcTyp reflect.Type) (convertFunc, bool) {
+	if srcTyp == dstTyp {
+		return setValue, true
+	}
+	switch {
+	case isNumberKind(dstTyp.Kind()) && isNumberKind(srcTyp.Kind()),
+		dstTyp.Kind() == srcTyp.Kind() && srcTyp.ConvertibleTo(dstTyp),
+		isBytesType(dstTyp) && srcTyp.Kind() == reflect.String,
+		dstTyp.Kind() == reflect.String && isBytesType(srcTyp):
+		return func(dst, src reflect.Value) {
+			dst.Set(src.Convert(dstTyp))
+		}, true
+	}
+	return nil, false
+}
+
+func setValue(dst, src reflect.Value) {
+	dst.Set(src)
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isBytesType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// ParseValue parse the string into a new value of the type.
+// NOTE:
+//  Support bool, numeric kinds, string, []byte, time.Duration, encoding.TextUnmarshaler (e.g. time.Time in RFC 3339),
+//  pointers to them, the slices and arrays in the form of `a,b,c`, and the maps in the form of `k1:v1,k2:v2`
+func ParseValue(typ reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(typ).Elem()
+	if err := parseInto(v, s); err != nil {
+		return zero, fmt.Errorf("cannot parse %q as %s: %v", s, typ, err)
+	}
+	return v, nil
+}
+
+// IsTextType report whether the type is parsed as a whole by encoding.TextUnmarshaler.
+// NOTE:
+//  Such a struct type is a leaf for the packages binding strings to fields, e.g. time.Time
+func IsTextType(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// parseInto parse the string into the addressable value.
+func parseInto(v reflect.Value, s string) error {
+	if IsTextType(v.Type()) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := parseInto(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if isBytesType(v.Type()) {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+		items := splitList(s)
+		sl := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := parseInto(sl.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(sl)
+	case reflect.Array:
+		items := splitList(s)
+		if len(items) > v.Len() {
+			return fmt.Errorf("too many elements, the array length is %d", v.Len())
+		}
+		for i, item := range items {
+			if err := parseInto(v.Index(i), item); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		items := splitList(s)
+		m := reflect.MakeMapWithSize(v.Type(), len(items))
+		for _, item := range items {
+			i := strings.IndexByte(item, ':')
+			if i < 0 {
+				return fmt.Errorf("missing ':' in the map entry %q", item)
+			}
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := parseInto(key, strings.TrimSpace(item[:i])); err != nil {
+				return err
+			}
+			val := reflect.New(v.Type().Elem()).Elem()
+			if err := parseInto(val, strings.TrimSpace(item[i+1:])); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, val)
+		}
+		v.Set(m)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return errors.New("unsupported type")
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return errors.New("unsupported type")
+	}
+	return nil
+}
+
+// splitList split the comma-separated list and trim the spaces of the items.
+func splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	items := strings.Split(s, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return items
+}
+
+// FormatValue format the value into the string which ParseValue can parse back.
+// NOTE:
+//  The nil pointers and interfaces are formatted as empty strings;
+//  The map entries are sorted by the formatted keys
+func FormatValue(v reflect.Value) string {
+	return string(appendValue(nil, v))
+}
+
+// appendValue append the formatted value to b.
+func appendValue(b []byte, v reflect.Value) []byte {
+	for {
+		if v.CanInterface() {
+			if v.Type().Implements(textMarshalerType) {
+				if v.Kind() == reflect.Ptr && v.IsNil() {
+					return b
+				}
+				text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+				if err != nil {
+					return b
+				}
+				return append(b, text...)
+			}
+			if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+				v = v.Addr()
+				continue
+			}
+		}
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+			break
+		}
+		if v.IsNil() {
+			return b
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return append(b, v.String()...)
+	case reflect.Bool:
+		return strconv.AppendBool(b, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			return append(b, time.Duration(v.Int()).String()...)
+		}
+		return strconv.AppendInt(b, v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(b, v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.AppendFloat(b, v.Float(), 'g', -1, v.Type().Bits())
+	case reflect.Slice, reflect.Array:
+		if isBytesType(v.Type()) {
+			return append(b, v.Bytes()...)
+		}
+		for i, n := 0, v.Len(); i < n; i++ {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendValue(b, v.Index(i))
+		}
+		return b
+	case reflect.Map:
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entry := appendValue(nil, iter.Key())
+			entry = append(entry, ':')
+			entries = append(entries, string(appendValue(entry, iter.Value())))
+		}
+		sort.Strings(entries)
+		return append(b, strings.Join(entries, ",")...)
+	}
+	return append(b, fmt.Sprint(v)...)
+}
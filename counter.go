@@ -0,0 +1,62 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+type (
+	// stripedCounter the counter whose additions from different goroutines rarely share a cache line
+	stripedCounter struct {
+		stripes []paddedCounter
+		shift   uint
+	}
+	paddedCounter struct {
+		n uint64
+		_ [120]byte // two cache lines, against the adjacent line prefetch
+	}
+)
+
+func newStripedCounter() *stripedCounter {
+	n, bits := 1, uint(0)
+	for n < runtime.GOMAXPROCS(0)*4 {
+		n <<= 1
+		bits++
+	}
+	return &stripedCounter{stripes: make([]paddedCounter, n), shift: 64 - bits}
+}
+
+// add add 1 to the stripe chosen by the stack address of the calling goroutine.
+func (c *stripedCounter) add() {
+	if c.shift == 64 {
+		atomic.AddUint64(&c.stripes[0].n, 1)
+		return
+	}
+	var local byte
+	// the goroutine stacks start at 2KB, so the bits below 11 are mostly the frame offset
+	h := uint64(uintptr(unsafe.Pointer(&local))>>11) * 0x9E3779B97F4A7C15
+	atomic.AddUint64(&c.stripes[h>>c.shift].n, 1)
+}
+
+func (c *stripedCounter) load() uint64 {
+	var sum uint64
+	for i := range c.stripes {
+		sum += atomic.LoadUint64(&c.stripes[i].n)
+	}
+	return sum
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DefaultTagKey the struct tag key of the default values, e.g. `default:"8080"`
+const DefaultTagKey = "default"
+
+type (
+	defaultPlan struct {
+		values []defaultValue
+		err    error
+	}
+	defaultValue struct {
+		field *FieldType
+		raw   string
+		// value the parsed value of the scalar kinds,
+		// the others are parsed on every use to avoid sharing the underlying data
+		value reflect.Value
+	}
+)
+
+// ApplyDefaults set the values of the `default` tag to the zero-valued fields of the struct that structPtr points to.
+// NOTE:
+//  The nil pointer fields are initialized only if they have default values;
+//  A non-nil pointer field is regarded as set, even if it points to a zero value
+func (s *StructType) ApplyDefaults(structPtr interface{}) error {
+	plan := s.getDefaultPlan()
+	if plan.err != nil {
+		return plan.err
+	}
+	a, err := s.AcquireAccess(structPtr)
+	if err != nil {
+		return err
+	}
+	defer s.ReleaseAccess(a)
+	return a.applyDefaults(plan)
+}
+
+// ApplyDefaults set the values of the `default` tag to the zero-valued fields.
+// NOTE:
+//  The nil pointer fields are initialized only if they have default values;
+//  A non-nil pointer field is regarded as set, even if it points to a zero value
+func (s *Struct) ApplyDefaults() error {
+	plan := s.StructType.getDefaultPlan()
+	if plan.err != nil {
+		return plan.err
+	}
+	return s.applyDefaults(plan)
+}
+
+func (s *Struct) applyDefaults(plan *defaultPlan) error {
+	for _, d := range plan.values {
+		if v, ok := s.lookup(d.field, true); ok && (d.field.ptrNum > 0 || !v.elemVal.IsZero()) {
+			continue
+		}
+		val := d.value
+		if !val.IsValid() {
+			var err error
+			val, err = ParseValue(d.field.elemTyp, d.raw)
+			if err != nil {
+				return fmt.Errorf("%s: %v", d.field.selector, err)
+			}
+		}
+		s.getOrInit(d.field, true).elemVal.Set(val)
+	}
+	return nil
+}
+
+// getDefaultPlan return the default values, which are parsed once.
+func (s *StructType) getDefaultPlan() *defaultPlan {
+	s.defaultsOnce.Do(func() {
+		plan := &defaultPlan{}
+		for _, f := range s.complete().fields {
+			raw, ok := f.Tag.Lookup(DefaultTagKey)
+			if !ok {
+				continue
+			}
+			val, err := ParseValue(f.elemTyp, raw)
+			if err != nil {
+				plan.err = fmt.Errorf("%s: %v", f.selector, err)
+				break
+			}
+			d := defaultValue{field: f, raw: raw}
+			switch f.elemTyp.Kind() {
+			case reflect.Bool, reflect.String,
+				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+				reflect.Float32, reflect.Float64:
+				d.value = val
+			}
+			plan.values = append(plan.values, d)
+		}
+		s.defaults = plan
+	})
+	return s.defaults
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"reflect"
+)
+
+// FieldDiff the difference of a leaf field between two struct instances
+type FieldDiff struct {
+	Type     *FieldType
+	Selector string
+	// Old the field value of the first struct, invalid if it is unreachable because of a nil pointer
+	Old reflect.Value
+	// New the field value of the second struct, invalid if it is unreachable because of a nil pointer
+	New reflect.Value
+}
+
+// Equal report whether all the leaf fields of the two struct pointers are deeply equal.
+// NOTE:
+//  The struct fields that have an Equal method or no exported fields are leaves, e.g. time.Time,
+//  and they are compared by the Equal method or deeply;
+//  The fields skipped by IteratorFunc are ignored;
+//  No nil pointer fields will be initialized;
+//  If a or b is not a pointer of the struct type, it will cause panic.
+func (s *StructType) Equal(a, b interface{}) bool {
+	return len(s.diff(a, b, true)) == 0
+}
+
+// Diff compare the leaf fields of the two struct pointers and return the differences.
+// NOTE:
+//  The struct fields that have an Equal method or no exported fields are leaves, e.g. time.Time,
+//  and they are compared by the Equal method or deeply;
+//  The fields skipped by IteratorFunc are ignored;
+//  No nil pointer fields will be initialized;
+//  If a or b is not a pointer of the struct type, it will cause panic.
+func (s *StructType) Diff(a, b interface{}) []FieldDiff {
+	return s.diff(a, b, false)
+}
+
+func (s *StructType) diff(a, b interface{}, stopFirst bool) []FieldDiff {
+	sa, sb := s.MustAccess(a), s.MustAccess(b)
+	var diffs []FieldDiff
+	for _, f := range s.complete().fields {
+		if !f.isLeaf() {
+			continue
+		}
+		va, oka := sa.lookup(f, true)
+		vb, okb := sb.lookup(f, true)
+		if oka == okb && (!oka || valueEqual(va.elemVal, vb.elemVal)) {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{Type: f, Selector: f.selector, Old: va.elemVal, New: vb.elemVal})
+		if stopFirst {
+			break
+		}
+	}
+	return diffs
+}
+
+// valueEqual report whether the two values of the same type are deeply equal.
+func valueEqual(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Struct:
+		if m, ok := equalMethod(a.Type()); ok {
+			return callEqual(m, a, b)
+		}
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	default:
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+}
+
+// callEqual call the Equal method of a with b.
+func callEqual(m reflect.Method, a, b reflect.Value) bool {
+	a, b = addressable(a), addressable(b)
+	arg := b
+	if m.Type.In(1) == m.Type.In(0) {
+		arg = b.Addr()
+	}
+	return m.Func.Call([]reflect.Value{a.Addr(), arg})[0].Bool()
+}
+
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Elem()
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package env populate the struct fields from the environment variables,
+// whose names are derived from the field selectors, e.g. `.P2.P3.E` -> `APP_P2_P3_E`,
+// or specified by the tags, e.g. `env:"PORT,required"`.
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/henrylee2cn/gofield"
+)
+
+type (
+	// Loader load the environment variables into the structs
+	Loader struct {
+		acc    *gofield.Accessor
+		prefix string
+		lookup func(name string) (string, bool)
+		plans  gofield.PlanCache[[]variable]
+	}
+	// Option loader option
+	Option func(*Loader)
+	// FieldError the field that failed to load
+	FieldError struct {
+		Selector string
+		Name     string // the variable name
+		Err      error
+	}
+	// Errors the field errors of a struct
+	Errors []*FieldError
+
+	variable struct {
+		field    *gofield.FieldType
+		elemTyp  reflect.Type
+		name     string
+		required bool
+	}
+)
+
+// TagKey the struct tag key of the variable names
+const TagKey = "env"
+
+var (
+	// ErrRequired the required variable is not set
+	ErrRequired = errors.New("required variable is not set")
+
+	defaultAccessor = gofield.New(gofield.WithTagKeys(TagKey))
+)
+
+// New create a loader.
+func New(opt ...Option) *Loader {
+	l := &Loader{
+		acc:    defaultAccessor,
+		lookup: os.LookupEnv,
+	}
+	for _, fn := range opt {
+		fn(l)
+	}
+	return l
+}
+
+// WithPrefix set the prefix of the variable names, e.g. `APP` -> `APP_P2_P3_E`.
+func WithPrefix(prefix string) Option {
+	return func(l *Loader) {
+		l.prefix = strings.ToUpper(prefix)
+	}
+}
+
+// WithLookup set the function that looks up the variables, default is os.LookupEnv.
+func WithLookup(fn func(name string) (string, bool)) Option {
+	return func(l *Loader) {
+		l.lookup = fn
+	}
+}
+
+// WithAccessor set the accessor which provides the struct type info,
+// so the type cache can be shared with the other users.
+func WithAccessor(acc *gofield.Accessor) Option {
+	return func(l *Loader) {
+		l.acc = acc
+	}
+}
+
+// Load load the environment variables into the struct that structPtr points to.
+func Load(structPtr interface{}, opt ...Option) error {
+	return New(opt...).Load(structPtr)
+}
+
+// Load load the environment variables into the struct that structPtr points to.
+// NOTE:
+//  Return Errors if any variable is missing or invalid
+func (l *Loader) Load(structPtr interface{}) error {
+	st, err := l.acc.Analyze(structPtr)
+	if err != nil {
+		return err
+	}
+	s, err := st.AcquireAccess(structPtr)
+	if err != nil {
+		return err
+	}
+	defer st.ReleaseAccess(s)
+	return l.LoadStruct(s)
+}
+
+// LoadStruct load the environment variables into the struct bound to the accessor.
+// NOTE:
+//  The nil pointer fields are initialized only if their variables are set;
+//  Return Errors if any variable is missing or invalid
+func (l *Loader) LoadStruct(s *gofield.Struct) error {
+	var errs Errors
+	for _, v := range l.plans.Load(s.StructType, l.compile) {
+		raw, ok := l.lookup(v.name)
+		if !ok {
+			if v.required {
+				errs = append(errs, &FieldError{Selector: v.field.Selector(), Name: v.name, Err: ErrRequired})
+			}
+			continue
+		}
+		val, err := gofield.ParseValue(v.elemTyp, raw)
+		if err != nil {
+			errs = append(errs, &FieldError{Selector: v.field.Selector(), Name: v.name, Err: err})
+			continue
+		}
+		s.FieldValue(v.field.ID()).Set(val)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Names return the variable names of the struct type, in the order of the field ids.
+func (l *Loader) Names(st *gofield.StructType) []string {
+	plan := l.plans.Load(st, l.compile)
+	names := make([]string, len(plan))
+	for i, v := range plan {
+		names[i] = v.name
+	}
+	return names
+}
+
+// compile return the variables of the leaf fields.
+func (l *Loader) compile(st *gofield.StructType) []variable {
+	var plan []variable
+	skipped := make(map[*gofield.FieldType]bool)
+	for id := 0; id < st.NumField(); id++ {
+		ft := st.FieldType(id)
+		if parent := ft.Parent(); parent != nil && skipped[parent] {
+			skipped[ft] = true
+			continue
+		}
+		tag, _ := ft.TagValue(TagKey)
+		if tag.Name == "-" || !gofield.PromotedExported.Includes(ft) {
+			skipped[ft] = true
+			continue
+		}
+		elemTyp := ft.ElemType()
+		if gofield.IsTextType(elemTyp) {
+			skipped[ft] = true
+		} else if elemTyp.Kind() == reflect.Struct {
+			continue
+		}
+		name := tag.Name
+		if name == "" {
+			name = strings.ToUpper(strings.ReplaceAll(strings.TrimPrefix(ft.Selector(), "."), ".", "_"))
+		}
+		if l.prefix != "" {
+			name = l.prefix + "_" + name
+		}
+		plan = append(plan, variable{
+			field:    ft,
+			elemTyp:  elemTyp,
+			name:     name,
+			required: tag.HasOption("required"),
+		})
+	}
+	return plan
+}
+
+// Error implement error interface.
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("env: %s(%s): %v", e.Name, e.Selector, e.Err)
+}
+
+// Unwrap return the underlying error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Error implement error interface.
+func (e Errors) Error() string {
+	a := make([]string, len(e))
+	for i, fe := range e {
+		a[i] = fe.Error()
+	}
+	return strings.Join(a, "; ")
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/henrylee2cn/gofield"
+	"github.com/henrylee2cn/gofield/env"
+)
+
+type (
+	Config struct {
+		Port    int `env:"PORT,required"`
+		Debug   bool
+		Timeout time.Duration
+		Hosts   []string
+		Started time.Time
+		DB      *DB
+		Cache   Cache
+		secret  string
+		Ignored string `env:"-"`
+	}
+	DB struct {
+		DSN     string `env:",required"`
+		MaxConn *int
+	}
+	Cache struct {
+		Size uint
+	}
+)
+
+func lookup(m map[string]string) env.Option {
+	return env.WithLookup(func(name string) (string, bool) {
+		v, ok := m[name]
+		return v, ok
+	})
+}
+
+func TestLoad(t *testing.T) {
+	vars := map[string]string{
+		"APP_PORT":        "8080",
+		"APP_DEBUG":       "true",
+		"APP_TIMEOUT":     "3s",
+		"APP_HOSTS":       "a, b",
+		"APP_STARTED":     "2020-01-02T03:04:05Z",
+		"APP_DB_DSN":      "mysql://localhost",
+		"APP_DB_MAXCONN":  "10",
+		"APP_CACHE_SIZE":  "64",
+		"APP_SECRET":      "x",
+		"APP_IGNORED":     "x",
+		"APP_UNKNOWN_VAR": "x",
+	}
+	var c Config
+	assert.NoError(t, env.Load(&c, env.WithPrefix("app"), lookup(vars)))
+	assert.Equal(t, 8080, c.Port)
+	assert.True(t, c.Debug)
+	assert.Equal(t, 3*time.Second, c.Timeout)
+	assert.Equal(t, []string{"a", "b"}, c.Hosts)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), c.Started)
+	assert.Equal(t, "mysql://localhost", c.DB.DSN)
+	assert.Equal(t, 10, *c.DB.MaxConn)
+	assert.Equal(t, uint(64), c.Cache.Size)
+	assert.Equal(t, "", c.secret)
+	assert.Equal(t, "", c.Ignored)
+
+	// the unset variables keep the field values, and the nil pointers are not initialized
+	c = Config{Port: 1, Debug: true, Cache: Cache{Size: 2}}
+	assert.NoError(t, env.Load(&c, env.WithPrefix("APP"), lookup(map[string]string{"APP_PORT": "80", "APP_DB_DSN": "dsn"})))
+	assert.Equal(t, Config{Port: 80, Debug: true, DB: &DB{DSN: "dsn"}, Cache: Cache{Size: 2}}, c)
+}
+
+func TestLoadError(t *testing.T) {
+	var c Config
+	err := env.Load(&c, env.WithPrefix("APP"), lookup(map[string]string{
+		"APP_DB_MAXCONN": "x",
+		"APP_CACHE_SIZE": "-1",
+	}))
+	errs, ok := err.(env.Errors)
+	assert.True(t, ok)
+	var selectors []string
+	for _, fe := range errs {
+		selectors = append(selectors, fe.Selector)
+	}
+	assert.Equal(t, []string{".Port", ".DB.DSN", ".DB.MaxConn", ".Cache.Size"}, selectors)
+	assert.True(t, errors.Is(errs[0], env.ErrRequired))
+	assert.EqualError(t, errs[0], "env: APP_PORT(.Port): required variable is not set")
+	assert.EqualError(t, errs[2], `env: APP_DB_MAXCONN(.DB.MaxConn): cannot parse "x" as int: strconv.ParseInt: parsing "x": invalid syntax`)
+	// the invalid variables do not initialize the struct pointers
+	assert.Nil(t, c.DB)
+
+	assert.EqualError(t, env.Load(Config{}), "type is not struct pointer")
+}
+
+func TestLoader(t *testing.T) {
+	t.Setenv("PORT", "9090")
+	t.Setenv("DB_DSN", "sqlite")
+	acc := gofield.New(gofield.WithTagKeys(env.TagKey))
+	l := env.New(env.WithAccessor(acc))
+	assert.Equal(t, []string{"PORT", "DEBUG", "TIMEOUT", "HOSTS", "STARTED", "DB_DSN", "DB_MAXCONN", "CACHE_SIZE"},
+		l.Names(acc.MustAnalyze(&Config{})))
+	var c Config
+	assert.NoError(t, l.LoadStruct(acc.MustAccess(&c)))
+	assert.Equal(t, 9090, c.Port)
+	assert.Equal(t, "sqlite", c.DB.DSN)
+}
+
+func TestPromotedFields(t *testing.T) {
+	type (
+		logging struct {
+			Level string
+		}
+		Service struct {
+			Name string
+			logging
+			hidden logging
+		}
+	)
+	var s Service
+	assert.NoError(t, env.Load(&s, lookup(map[string]string{"NAME": "x", "LOGGING_LEVEL": "debug", "HIDDEN_LEVEL": "info"})))
+	// the exported fields of the unexported embedded struct are loaded
+	assert.Equal(t, Service{Name: "x", logging: logging{Level: "debug"}}, s)
+	assert.Equal(t, []string{"NAME", "LOGGING_LEVEL"}, env.New().Names(gofield.MustAnalyze(&Service{})))
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flags register the command-line flags of the struct fields,
+// whose names are the lowercase field selectors, e.g. `.P2.P3.E` -> `-p2.p3.e`,
+// or specified by the tags, e.g. `flag:"port" usage:"the listening port"`.
+package flags
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/henrylee2cn/gofield"
+)
+
+type (
+	// Option binding option
+	Option  func(*binding)
+	binding struct {
+		acc    *gofield.Accessor
+		prefix string
+	}
+	// fieldValue the flag.Value writing to the struct field
+	fieldValue struct {
+		s       *gofield.Struct
+		field   *gofield.FieldType
+		elemTyp reflect.Type
+		set     bool
+	}
+)
+
+const (
+	// TagKey the struct tag key of the flag names
+	TagKey = "flag"
+	// UsageTagKey the struct tag key of the help texts
+	UsageTagKey = "usage"
+)
+
+var defaultAccessor = gofield.New(gofield.WithTagKeys(TagKey))
+
+// WithAccessor set the accessor which provides the struct type info,
+// so the type cache can be shared with the other users.
+func WithAccessor(acc *gofield.Accessor) Option {
+	return func(b *binding) {
+		b.acc = acc
+	}
+}
+
+// WithPrefix set the prefix of the flag names, e.g. `db.` -> `-db.p2.p3.e`,
+// which is used to bind several structs to the same flag set.
+func WithPrefix(prefix string) Option {
+	return func(b *binding) {
+		b.prefix = prefix
+	}
+}
+
+// Bind register the flags of the leaf fields of the struct that structPtr points to.
+// NOTE:
+//  The struct is held by the flag set, and the parsed values are written into it;
+//  The current field values are shown as the defaults in the help text
+func Bind(fs *flag.FlagSet, structPtr interface{}, opt ...Option) error {
+	b := newBinding(opt)
+	s, err := b.acc.Access(structPtr)
+	if err != nil {
+		return err
+	}
+	return b.bind(fs, s)
+}
+
+// BindStruct register the flags of the leaf fields of the struct bound to the accessor.
+// NOTE:
+//  The accessor is held by the flag set, and the parsed values are written into its struct;
+//  The current field values are shown as the defaults in the help text
+func BindStruct(fs *flag.FlagSet, s *gofield.Struct, opt ...Option) error {
+	return newBinding(opt).bind(fs, s)
+}
+
+func newBinding(opt []Option) *binding {
+	b := &binding{acc: defaultAccessor}
+	for _, fn := range opt {
+		fn(b)
+	}
+	return b
+}
+
+func (b *binding) bind(fs *flag.FlagSet, s *gofield.Struct) error {
+	skipped := make(map[*gofield.FieldType]bool)
+	for id := 0; id < s.NumField(); id++ {
+		ft := s.FieldType(id)
+		if parent := ft.Parent(); parent != nil && skipped[parent] {
+			skipped[ft] = true
+			continue
+		}
+		tag, _ := ft.TagValue(TagKey)
+		if tag.Name == "-" || !gofield.PromotedExported.Includes(ft) {
+			skipped[ft] = true
+			continue
+		}
+		elemTyp := ft.ElemType()
+		if gofield.IsTextType(elemTyp) {
+			skipped[ft] = true
+		} else if elemTyp.Kind() == reflect.Struct {
+			continue
+		}
+		name := tag.Name
+		if name == "" {
+			name = strings.ToLower(strings.TrimPrefix(ft.Selector(), "."))
+		}
+		name = b.prefix + name
+		if fs.Lookup(name) != nil {
+			return fmt.Errorf("flags: flag redefined: %s(%s)", name, ft.Selector())
+		}
+		fs.Var(&fieldValue{s: s, field: ft, elemTyp: elemTyp}, name, ft.Tag.Get(UsageTagKey))
+	}
+	return nil
+}
+
+// String implement flag.Value interface.
+// NOTE:
+//  The zero values are formatted as empty strings, so that they are not shown as the defaults
+func (v *fieldValue) String() string {
+	// flag.PrintDefaults calls String on the zero fieldValue
+	if v.s == nil {
+		return ""
+	}
+	val, ok := v.s.LookupValue(v.field.ID())
+	if !ok || val.IsZero() {
+		return ""
+	}
+	return gofield.FormatValue(val)
+}
+
+// Set implement flag.Value interface.
+// NOTE:
+//  The repeated flags of a slice append to the values set by the command line,
+//  the initial elements are replaced
+func (v *fieldValue) Set(s string) error {
+	val, err := gofield.ParseValue(v.elemTyp, s)
+	if err != nil {
+		return err
+	}
+	dst := v.s.FieldValue(v.field.ID())
+	if v.set && v.elemTyp.Kind() == reflect.Slice && v.elemTyp.Elem().Kind() != reflect.Uint8 {
+		val = reflect.AppendSlice(dst, val)
+	}
+	dst.Set(val)
+	v.set = true
+	return nil
+}
+
+// IsBoolFlag report whether the flag can be set without a value, e.g. `-debug`.
+func (v *fieldValue) IsBoolFlag() bool {
+	return v.elemTyp != nil && v.elemTyp.Kind() == reflect.Bool
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flags_test
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/henrylee2cn/gofield"
+	"github.com/henrylee2cn/gofield/flags"
+)
+
+type (
+	Options struct {
+		Addr    string        `flag:"addr" usage:"the listening address"`
+		Debug   bool          `usage:"enable the debug mode"`
+		Timeout time.Duration `usage:"the request timeout"`
+		Peers   []string      `usage:"the peer addresses"`
+		DB      *DB
+		secret  string
+		Ignored string `flag:"-"`
+	}
+	DB struct {
+		DSN     string
+		MaxConn *int
+	}
+)
+
+func TestBind(t *testing.T) {
+	o := Options{Addr: ":8080", Timeout: time.Second, Peers: []string{"a"}}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	assert.NoError(t, flags.Bind(fs, &o))
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	assert.Equal(t, []string{"addr", "db.dsn", "db.maxconn", "debug", "peers", "timeout"}, names)
+	assert.Equal(t, "1s", fs.Lookup("timeout").DefValue)
+	assert.Nil(t, o.DB)
+
+	err := fs.Parse([]string{"-debug", "-addr=:9090", "-timeout", "3s", "-peers", "b,c", "-peers", "d", "-db.maxconn", "10", "arg"})
+	assert.NoError(t, err)
+	assert.Equal(t, ":9090", o.Addr)
+	assert.True(t, o.Debug)
+	assert.Equal(t, 3*time.Second, o.Timeout)
+	assert.Equal(t, []string{"b", "c", "d"}, o.Peers)
+	assert.Equal(t, 10, *o.DB.MaxConn)
+	assert.Equal(t, "", o.DB.DSN)
+	assert.Equal(t, []string{"arg"}, fs.Args())
+	assert.Equal(t, "b,c,d", fs.Lookup("peers").Value.String())
+
+	var out bytes.Buffer
+	fs.SetOutput(&out)
+	fs.PrintDefaults()
+	assert.Contains(t, out.String(), "-addr value\n    \tthe listening address (default :8080)\n")
+	assert.Contains(t, out.String(), "-debug\n    \tenable the debug mode\n")
+
+	err = fs.Parse([]string{"-db.maxconn", "x"})
+	assert.EqualError(t, err, `invalid value "x" for flag -db.maxconn: cannot parse "x" as int: strconv.ParseInt: parsing "x": invalid syntax`)
+}
+
+func TestBindStruct(t *testing.T) {
+	acc := gofield.New(gofield.WithTagKeys(flags.TagKey))
+	var a, b DB
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	assert.NoError(t, flags.BindStruct(fs, acc.MustAccess(&a), flags.WithAccessor(acc), flags.WithPrefix("a.")))
+	assert.NoError(t, flags.Bind(fs, &b, flags.WithAccessor(acc), flags.WithPrefix("b.")))
+	assert.NoError(t, fs.Parse([]string{"-a.dsn", "x", "-b.dsn", "y"}))
+	assert.Equal(t, "x", a.DSN)
+	assert.Equal(t, "y", b.DSN)
+
+	assert.EqualError(t, flags.Bind(fs, &b, flags.WithPrefix("b.")), "flags: flag redefined: b.dsn(.DSN)")
+	assert.EqualError(t, flags.Bind(fs, b), "type is not struct pointer")
+}
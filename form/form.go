@@ -0,0 +1,411 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package form bind the url.Values, such as the query strings and the form data, to the structs,
+// whose keys are the dot-separated field names or `form` tag names, e.g. `user.name`,
+// and the slice elements are selected by the indexed keys, e.g. `items[0].name`.
+package form
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/henrylee2cn/ameda"
+	"github.com/henrylee2cn/gofield"
+)
+
+type (
+	// Codec decode the url.Values into the structs, and encode the structs into the url.Values
+	Codec struct {
+		acc      *gofield.Accessor
+		tagKey   string
+		maxIndex int
+		plans    gofield.PlanCache[*structPlan]
+	}
+	// Option codec option
+	Option func(*Codec)
+	// FieldError the field that failed to decode
+	FieldError struct {
+		Key      string
+		Selector string
+		Err      error
+	}
+	// Errors the field errors of a struct
+	Errors []*FieldError
+
+	structPlan struct {
+		fields []*fieldPlan
+		index  map[string]*fieldPlan // key is the form key
+	}
+	fieldPlan struct {
+		field   *gofield.FieldType
+		key     string
+		elemTyp reflect.Type
+		// repeated the slice or array bound by the repeated keys
+		repeated bool
+		// itemType the struct type of the slice or array elements bound by the indexed keys
+		itemType *gofield.StructType
+	}
+)
+
+const (
+	// DefaultTagKey the default struct tag key of the form keys
+	DefaultTagKey = "form"
+	// DefaultMaxIndex the default maximum index of the indexed keys
+	DefaultMaxIndex = 1000
+)
+
+var defaultCodec = New()
+
+// New create a codec.
+func New(opt ...Option) *Codec {
+	c := &Codec{
+		tagKey:   DefaultTagKey,
+		maxIndex: DefaultMaxIndex,
+	}
+	for _, fn := range opt {
+		fn(c)
+	}
+	if c.acc == nil {
+		c.acc = gofield.New(gofield.WithTagKeys(c.tagKey))
+	}
+	return c
+}
+
+// WithAccessor set the accessor which provides the struct type info,
+// so the type cache can be shared with the other users.
+func WithAccessor(acc *gofield.Accessor) Option {
+	return func(c *Codec) {
+		c.acc = acc
+	}
+}
+
+// WithTagKey set the struct tag key of the form keys, default is `form`.
+func WithTagKey(key string) Option {
+	return func(c *Codec) {
+		c.tagKey = key
+	}
+}
+
+// WithMaxIndex set the maximum index of the indexed keys, default is DefaultMaxIndex,
+// which limits the slice length allocated by a request.
+func WithMaxIndex(n int) Option {
+	return func(c *Codec) {
+		c.maxIndex = n
+	}
+}
+
+// Decode decode the values into the struct that structPtr points to by the default codec.
+func Decode(values url.Values, structPtr interface{}) error {
+	return defaultCodec.Decode(values, structPtr)
+}
+
+// Encode encode the struct that structPtr points to into the values by the default codec.
+func Encode(structPtr interface{}) (url.Values, error) {
+	return defaultCodec.Encode(structPtr)
+}
+
+// Decode decode the values into the struct that structPtr points to.
+// NOTE:
+//  The unknown keys are ignored;
+//  Return Errors if any value is invalid
+func (c *Codec) Decode(values url.Values, structPtr interface{}) error {
+	st, err := c.acc.Analyze(structPtr)
+	if err != nil {
+		return err
+	}
+	s, err := st.AcquireAccess(structPtr)
+	if err != nil {
+		return err
+	}
+	defer st.ReleaseAccess(s)
+	return c.DecodeStruct(values, s)
+}
+
+// DecodeStruct decode the values into the struct bound to the accessor.
+// NOTE:
+//  The nil pointer fields are initialized only if their keys are present;
+//  The slices are grown to hold the indexed elements;
+//  Return Errors if any value is invalid
+func (c *Codec) DecodeStruct(values url.Values, s *gofield.Struct) error {
+	var errs Errors
+	c.decode(values, s, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c *Codec) decode(values url.Values, s *gofield.Struct, prefix string, errs *Errors) {
+	plan := c.plans.Load(s.StructType, c.compile)
+	// group the indexed keys by the field, e.g. `items[0].name` -> items: {0: {name: ...}}
+	var indexed map[*fieldPlan]map[int]url.Values
+	for key, vals := range values {
+		i := strings.IndexByte(key, '[')
+		if i < 0 {
+			continue
+		}
+		f := plan.index[key[:i]]
+		if f == nil || (f.itemType == nil && !f.repeated) {
+			continue
+		}
+		idx, rest, ok := splitIndex(key[i:])
+		if !ok || idx > c.maxIndex || (f.itemType == nil && rest != "") {
+			continue
+		}
+		if indexed == nil {
+			indexed = make(map[*fieldPlan]map[int]url.Values)
+		}
+		items := indexed[f]
+		if items == nil {
+			items = make(map[int]url.Values)
+			indexed[f] = items
+		}
+		sub := items[idx]
+		if sub == nil {
+			sub = make(url.Values)
+			items[idx] = sub
+		}
+		sub[rest] = append(sub[rest], vals...)
+	}
+	for _, f := range plan.fields {
+		if vals := values[f.key]; len(vals) > 0 && f.itemType == nil {
+			val, err := f.parse(vals)
+			if err != nil {
+				*errs = append(*errs, &FieldError{Key: prefix + f.key, Selector: f.field.Selector(), Err: err})
+			} else {
+				s.FieldValue(f.field.ID()).Set(val)
+			}
+		}
+		if items := indexed[f]; items != nil {
+			c.decodeItems(items, s, f, prefix, errs)
+		}
+	}
+}
+
+// decodeItems decode the values of the indexed keys into the slice or array elements.
+func (c *Codec) decodeItems(items map[int]url.Values, s *gofield.Struct, f *fieldPlan, prefix string, errs *Errors) {
+	indexes := make([]int, 0, len(items))
+	for idx := range items {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+	v := s.FieldValue(f.field.ID())
+	if n := indexes[len(indexes)-1] + 1; n > v.Len() {
+		if v.Kind() == reflect.Array {
+			*errs = append(*errs, &FieldError{
+				Key:      prefix + f.key + "[" + strconv.Itoa(n-1) + "]",
+				Selector: f.field.Selector(),
+				Err:      fmt.Errorf("index out of range, the array length is %d", v.Len()),
+			})
+			return
+		}
+		sl := reflect.MakeSlice(v.Type(), n, n)
+		reflect.Copy(sl, v)
+		v.Set(sl)
+	}
+	for _, idx := range indexes {
+		key := prefix + f.key + "[" + strconv.Itoa(idx) + "]"
+		item := v.Index(idx)
+		if f.itemType == nil {
+			vals := items[idx][""]
+			if len(vals) == 0 {
+				continue
+			}
+			val, err := gofield.ParseValue(item.Type(), vals[0])
+			if err != nil {
+				*errs = append(*errs, &FieldError{Key: key, Selector: f.field.Selector(), Err: err})
+				continue
+			}
+			item.Set(val)
+			continue
+		}
+		for item.Kind() == reflect.Ptr {
+			if item.IsNil() {
+				item.Set(reflect.New(item.Type().Elem()))
+			}
+			item = item.Elem()
+		}
+		elem := f.itemType.AccessPointer(unsafe.Pointer(item.UnsafeAddr()))
+		c.decode(items[idx], elem, key+".", errs)
+	}
+}
+
+// parse parse the values of the field, one value per element for the slice and array.
+func (f *fieldPlan) parse(vals []string) (reflect.Value, error) {
+	if !f.repeated {
+		return gofield.ParseValue(f.elemTyp, vals[0])
+	}
+	var v reflect.Value
+	if f.elemTyp.Kind() == reflect.Array {
+		if len(vals) > f.elemTyp.Len() {
+			return v, fmt.Errorf("too many values, the array length is %d", f.elemTyp.Len())
+		}
+		v = reflect.New(f.elemTyp).Elem()
+	} else {
+		v = reflect.MakeSlice(f.elemTyp, len(vals), len(vals))
+	}
+	for i, s := range vals {
+		item, err := gofield.ParseValue(f.elemTyp.Elem(), s)
+		if err != nil {
+			return v, err
+		}
+		v.Index(i).Set(item)
+	}
+	return v, nil
+}
+
+// splitIndex split `[3].name` into 3 and `name`.
+func splitIndex(expr string) (int, string, bool) {
+	end := strings.IndexByte(expr, ']')
+	if end < 0 {
+		return 0, "", false
+	}
+	idx, err := strconv.Atoi(expr[1:end])
+	if err != nil || idx < 0 {
+		return 0, "", false
+	}
+	rest := expr[end+1:]
+	if rest != "" {
+		if rest[0] != '.' {
+			return 0, "", false
+		}
+		rest = rest[1:]
+	}
+	return idx, rest, true
+}
+
+// Encode encode the struct that structPtr points to into the values.
+func (c *Codec) Encode(structPtr interface{}) (url.Values, error) {
+	st, err := c.acc.Analyze(structPtr)
+	if err != nil {
+		return nil, err
+	}
+	s, err := st.AcquireAccess(structPtr)
+	if err != nil {
+		return nil, err
+	}
+	defer st.ReleaseAccess(s)
+	return c.EncodeStruct(s), nil
+}
+
+// EncodeStruct encode the struct bound to the accessor into the values.
+// NOTE:
+//  No nil pointer fields will be initialized, the unreachable fields are omitted;
+//  The slices and arrays are encoded as the repeated keys, or the indexed keys for the struct elements
+func (c *Codec) EncodeStruct(s *gofield.Struct) url.Values {
+	values := make(url.Values)
+	c.encode(values, s, "")
+	return values
+}
+
+func (c *Codec) encode(values url.Values, s *gofield.Struct, prefix string) {
+	for _, f := range c.plans.Load(s.StructType, c.compile).fields {
+		v, ok := s.LookupValue(f.field.ID())
+		if !ok {
+			continue
+		}
+		key := prefix + f.key
+		switch {
+		case f.itemType != nil:
+			for i, n := 0, v.Len(); i < n; i++ {
+				item := v.Index(i)
+				for item.Kind() == reflect.Ptr && !item.IsNil() {
+					item = item.Elem()
+				}
+				if item.Kind() == reflect.Ptr {
+					continue
+				}
+				elem := f.itemType.AccessPointer(unsafe.Pointer(item.UnsafeAddr()))
+				c.encode(values, elem, key+"["+strconv.Itoa(i)+"].")
+			}
+		case f.repeated:
+			for i, n := 0, v.Len(); i < n; i++ {
+				values[key] = append(values[key], gofield.FormatValue(v.Index(i)))
+			}
+		default:
+			values[key] = append(values[key], gofield.FormatValue(v))
+		}
+	}
+}
+
+// compile return the form keys of the leaf fields and the slices or arrays of structs.
+func (c *Codec) compile(st *gofield.StructType) *structPlan {
+	plan := &structPlan{index: make(map[string]*fieldPlan)}
+	keys := make(map[*gofield.FieldType]string) // the keys of the struct fields, absent if skipped
+	for id := 0; id < st.NumField(); id++ {
+		ft := st.FieldType(id)
+		var parentKey string
+		if parent := ft.Parent(); parent != nil {
+			k, ok := keys[parent]
+			if !ok {
+				continue
+			}
+			parentKey = k + "."
+		}
+		tag, _ := ft.TagValue(c.tagKey)
+		if tag.Name == "-" || !gofield.PromotedExported.Includes(ft) {
+			continue
+		}
+		name := tag.Name
+		if name == "" {
+			name = ft.Name
+		}
+		f := &fieldPlan{field: ft, key: parentKey + name, elemTyp: ft.ElemType()}
+		if !gofield.IsTextType(f.elemTyp) {
+			switch f.elemTyp.Kind() {
+			case reflect.Struct:
+				keys[ft] = f.key
+				continue
+			case reflect.Slice, reflect.Array:
+				item := ameda.DereferenceType(f.elemTyp.Elem())
+				if f.elemTyp.Kind() == reflect.Slice && item.Kind() == reflect.Uint8 {
+					break
+				}
+				if item.Kind() == reflect.Struct && !gofield.IsTextType(item) {
+					f.itemType = c.acc.MustAnalyze(reflect.New(item).Interface())
+				} else {
+					f.repeated = true
+				}
+			}
+		}
+		plan.fields = append(plan.fields, f)
+		plan.index[f.key] = f
+	}
+	return plan
+}
+
+// Error implement error interface.
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("form: %s(%s): %v", e.Key, e.Selector, e.Err)
+}
+
+// Unwrap return the underlying error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Error implement error interface.
+func (e Errors) Error() string {
+	a := make([]string, len(e))
+	for i, fe := range e {
+		a[i] = fe.Error()
+	}
+	return strings.Join(a, "; ")
+}
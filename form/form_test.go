@@ -0,0 +1,157 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package form_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/henrylee2cn/gofield"
+	"github.com/henrylee2cn/gofield/form"
+)
+
+type (
+	Order struct {
+		ID      int64     `form:"id"`
+		Paid    bool      `form:"paid"`
+		Created time.Time `form:"created"`
+		Tags    []string  `form:"tags"`
+		Scores  [2]int    `form:"scores"`
+		Buyer   *User     `form:"buyer"`
+		Items   []*Item   `form:"items"`
+		Note    string
+		Skipped string `form:"-"`
+	}
+	User struct {
+		Name  string `form:"name"`
+		Email string `form:"email"`
+	}
+	Item struct {
+		SKU    string   `form:"sku"`
+		Count  uint     `form:"count"`
+		Labels []string `form:"labels"`
+	}
+)
+
+func TestDecode(t *testing.T) {
+	values, err := url.ParseQuery("id=7&paid=true&created=2020-01-02T03:04:05Z&tags=a&tags=b&scores=1&scores=2" +
+		"&buyer.name=henry&items[1].sku=B&items[1].labels=x&items[1].labels=y&items[0].sku=A&items[0].count=2" +
+		"&Note=hi&Skipped=x&unknown=x")
+	assert.NoError(t, err)
+	var o Order
+	assert.NoError(t, form.Decode(values, &o))
+	assert.Equal(t, Order{
+		ID:      7,
+		Paid:    true,
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Tags:    []string{"a", "b"},
+		Scores:  [2]int{1, 2},
+		Buyer:   &User{Name: "henry"},
+		Items:   []*Item{{SKU: "A", Count: 2}, {SKU: "B", Labels: []string{"x", "y"}}},
+		Note:    "hi",
+	}, o)
+
+	// the indexed keys of the scalar slice, and the existing elements are kept
+	o = Order{Tags: []string{"a", "b"}, Items: []*Item{{SKU: "A", Count: 1}}}
+	assert.NoError(t, form.Decode(url.Values{"tags[2]": {"c"}, "items[0].count": {"3"}}, &o))
+	assert.Equal(t, []string{"a", "b", "c"}, o.Tags)
+	assert.Equal(t, []*Item{{SKU: "A", Count: 3}}, o.Items)
+	assert.Nil(t, o.Buyer)
+}
+
+func TestDecodeError(t *testing.T) {
+	var o Order
+	err := form.Decode(url.Values{
+		"id":             {"x"},
+		"scores":         {"1", "2", "3"},
+		"items[0].count": {"-1"},
+		"items[1].sku":   {"B"},
+	}, &o)
+	assert.EqualError(t, err, `form: id(.ID): cannot parse "x" as int64: strconv.ParseInt: parsing "x": invalid syntax; `+
+		`form: scores(.Scores): too many values, the array length is 2; `+
+		`form: items[0].count(.Count): cannot parse "-1" as uint: strconv.ParseUint: parsing "-1": invalid syntax`)
+	assert.Equal(t, "B", o.Items[1].SKU)
+
+	err = form.Decode(url.Values{"scores[2]": {"1"}}, &o)
+	assert.EqualError(t, err, "form: scores[2](.Scores): index out of range, the array length is 2")
+
+	// the indexes beyond the limit are ignored
+	c := form.New(form.WithMaxIndex(1))
+	assert.NoError(t, c.Decode(url.Values{"tags[2]": {"c"}}, &o))
+	assert.Nil(t, o.Tags)
+
+	assert.EqualError(t, form.Decode(nil, Order{}), "type is not struct pointer")
+}
+
+func TestEncode(t *testing.T) {
+	o := Order{
+		ID:      7,
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Tags:    []string{"a", "b"},
+		Items:   []*Item{{SKU: "A", Count: 2}, nil, {SKU: "C", Labels: []string{"x"}}},
+		Skipped: "x",
+	}
+	values, err := form.Encode(&o)
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{
+		"id":              {"7"},
+		"paid":            {"false"},
+		"created":         {"2020-01-02T03:04:05Z"},
+		"tags":            {"a", "b"},
+		"scores":          {"0", "0"},
+		"items[0].sku":    {"A"},
+		"items[0].count":  {"2"},
+		"items[2].sku":    {"C"},
+		"items[2].count":  {"0"},
+		"items[2].labels": {"x"},
+		"Note":            {""},
+	}, values)
+	// the nil pointers are not initialized
+	assert.Nil(t, o.Buyer)
+
+	var decoded Order
+	assert.NoError(t, form.Decode(values, &decoded))
+	assert.Equal(t, o.Tags, decoded.Tags)
+	assert.Equal(t, []*Item{{SKU: "A", Count: 2}, nil, {SKU: "C", Labels: []string{"x"}}}, decoded.Items)
+}
+
+func TestCodec(t *testing.T) {
+	type Query struct {
+		Page  int    `query:"page"`
+		Sort  string `query:"sort"`
+		Limit *int
+	}
+	acc := gofield.New(gofield.WithTagKeys("query"))
+	c := form.New(form.WithAccessor(acc), form.WithTagKey("query"))
+	var q Query
+	assert.NoError(t, c.DecodeStruct(url.Values{"page": {"2"}, "sort": {"name"}, "Limit": {"10"}}, acc.MustAccess(&q)))
+	assert.Equal(t, 2, q.Page)
+	assert.Equal(t, "name", q.Sort)
+	assert.Equal(t, 10, *q.Limit)
+	assert.Equal(t, url.Values{"page": {"2"}, "sort": {"name"}, "Limit": {"10"}}, c.EncodeStruct(acc.MustAccess(&q)))
+}
+
+func BenchmarkDecode(b *testing.B) {
+	values, _ := url.ParseQuery("id=7&paid=true&tags=a&tags=b&buyer.name=henry&items[0].sku=A&items[0].count=2&items[1].sku=B")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var o Order
+		_ = form.Decode(values, &o)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"reflect"
+)
+
+// TypedField the field accessor of type T, which is validated once and reused across struct accessors
+type TypedField[T any] struct {
+	st    *StructType
+	field *FieldType
+}
+
+// Field validate the field corresponding to the id against T, and return its typed accessor.
+// NOTE:
+//  T must be the field type after dereferencing pointers, otherwise return type mismatch error
+func Field[T any](st *StructType, id int) (TypedField[T], error) {
+	f := st.field(id)
+	if f == nil {
+		return TypedField[T]{}, errIllegalID
+	}
+	if f.ptrTyp != reflect.TypeOf((*T)(nil)) {
+		return TypedField[T]{}, errTypeMismatch
+	}
+	return TypedField[T]{st: st, field: f}, nil
+}
+
+// FieldType get the field type info.
+func (f TypedField[T]) FieldType() *FieldType {
+	return f.field
+}
+
+// Get get the field value.
+// NOTE:
+//  s must be accessed through the struct type which the field is validated from, otherwise panic;
+//  Return the zero value if the field is unreachable because of the nil pointers
+func (f TypedField[T]) Get(s *Struct) T {
+	f.check(s)
+	if v, ok := s.lookup(f.field, false); ok {
+		return *(*T)(v.elemPtr)
+	}
+	var zero T
+	return zero
+}
+
+// Set set the field value.
+// NOTE:
+//  s must be accessed through the struct type which the field is validated from, otherwise panic;
+//  By the way, the relevant nil pointer fields will be initialized
+func (f TypedField[T]) Set(s *Struct, v T) {
+	*f.Ptr(s) = v
+}
+
+// Ptr get the field pointer.
+// NOTE:
+//  s must be accessed through the struct type which the field is validated from, otherwise panic;
+//  By the way, the relevant nil pointer fields will be initialized
+func (f TypedField[T]) Ptr(s *Struct) *T {
+	f.check(s)
+	return (*T)(s.getOrInit(f.field, false).elemPtr)
+}
+
+func (f TypedField[T]) check(s *Struct) {
+	if s.StructType != f.st {
+		panic(errTypeMismatch)
+	}
+}
+
+// Get get the field value corresponding to the id.
+// NOTE:
+//  T must be the field type after dereferencing pointers, otherwise return type mismatch error;
+//  Return the zero value if the field is unreachable because of the nil pointers
+func Get[T any](s *Struct, id int) (T, error) {
+	f, err := Field[T](s.StructType, id)
+	if err != nil {
+		var v T
+		return v, err
+	}
+	return f.Get(s), nil
+}
+
+// Set set the field value corresponding to the id.
+// NOTE:
+//  T must be the field type after dereferencing pointers, otherwise return type mismatch error;
+//  By the way, the relevant nil pointer fields will be initialized
+func Set[T any](s *Struct, id int, v T) error {
+	f, err := Field[T](s.StructType, id)
+	if err != nil {
+		return err
+	}
+	f.Set(s, v)
+	return nil
+}
+
+// Ptr get the field pointer corresponding to the id.
+// NOTE:
+//  Return nil if the id is invalid or T is not the field type after dereferencing pointers;
+//  By the way, the relevant nil pointer fields will be initialized
+func Ptr[T any](s *Struct, id int) *T {
+	f, err := Field[T](s.StructType, id)
+	if err != nil {
+		return nil
+	}
+	return f.Ptr(s)
+}
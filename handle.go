@@ -0,0 +1,123 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+type (
+	// Handle compiled field accessor, which can be reused across struct instances
+	Handle struct {
+		field *FieldType
+		steps []handleStep
+	}
+	handleStep struct {
+		offset  uintptr
+		ptrTyps []reflect.Type // the type pointed to by each pointer level
+	}
+)
+
+// Handle compile the accessor of the field corresponding to the id.
+func (s *StructType) Handle(id int) (*Handle, error) {
+	if !s.checkID(id) {
+		return nil, errIllegalID
+	}
+	return newHandle(s.field(id)), nil
+}
+
+// HandleBySelector compile the accessor of the field corresponding to the selector.
+func (s *StructType) HandleBySelector(selector string) (*Handle, error) {
+	f, err := s.FieldTypeBySelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return newHandle(f), nil
+}
+
+func newHandle(f *FieldType) *Handle {
+	var chain []*FieldType
+	for p := f; p.parent != nil; p = p.parent {
+		chain = append(chain, p)
+	}
+	h := &Handle{field: f}
+	var step handleStep
+	for i := len(chain) - 1; i >= 0; i-- {
+		step.offset += chain[i].Offset
+		for t := chain[i].Type; t.Kind() == reflect.Ptr; t = t.Elem() {
+			step.ptrTyps = append(step.ptrTyps, t.Elem())
+		}
+		// merge the offsets of the fields that are not pointers
+		if len(step.ptrTyps) > 0 {
+			h.steps = append(h.steps, step)
+			step = handleStep{}
+		}
+	}
+	if step.offset > 0 || len(h.steps) == 0 {
+		h.steps = append(h.steps, step)
+	}
+	return h
+}
+
+// FieldType get the field type info.
+func (h *Handle) FieldType() *FieldType {
+	return h.field
+}
+
+// Ptr get the field pointer(after dereferencing pointers) in the struct that structPtr points to.
+// NOTE:
+//  structPtr must point to the struct type which the handle is compiled from;
+//  By the way, the relevant nil pointer fields will be initialized
+func (h *Handle) Ptr(structPtr unsafe.Pointer) unsafe.Pointer {
+	p := structPtr
+	for _, step := range h.steps {
+		p = unsafe.Add(p, step.offset)
+		for _, t := range step.ptrTyps {
+			pp := (*unsafe.Pointer)(p)
+			if *pp == nil {
+				*pp = reflect.New(t).UnsafePointer()
+			}
+			p = *pp
+		}
+	}
+	return p
+}
+
+// Lookup get the field pointer(after dereferencing pointers) in the struct that structPtr points to.
+// NOTE:
+//  structPtr must point to the struct type which the handle is compiled from;
+//  No nil pointer fields will be initialized, return false if the field is unreachable
+func (h *Handle) Lookup(structPtr unsafe.Pointer) (unsafe.Pointer, bool) {
+	p := structPtr
+	for _, step := range h.steps {
+		p = unsafe.Add(p, step.offset)
+		for range step.ptrTyps {
+			p = *(*unsafe.Pointer)(p)
+			if p == nil {
+				return nil, false
+			}
+		}
+	}
+	return p, true
+}
+
+// Value get the field value in the struct that structPtr points to.
+// NOTE:
+//  structPtr must point to the struct type which the handle is compiled from;
+//  By the way, the relevant nil pointer fields will be initialized
+func (h *Handle) Value(structPtr unsafe.Pointer) reflect.Value {
+	return h.field.elemValueAt(h.Ptr(structPtr))
+}
@@ -0,0 +1,955 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/henrylee2cn/ameda"
+	"github.com/henrylee2cn/gofield"
+)
+
+type (
+	// Decoder read and decode JSON values from an input stream
+	Decoder struct {
+		dec                   *json.Decoder
+		useNumber             bool
+		disallowUnknownFields bool
+	}
+	decodeState struct {
+		data                  []byte
+		off                   int
+		scratch               []byte
+		useNumber             bool
+		disallowUnknownFields bool
+		savedError            error
+		// the context of the value being decoded, for the type errors
+		errStruct string      // name of the top-level type
+		errPath   []pathToken // from the top-level value to the value being decoded
+	}
+	// pathToken the object key or the array index in the path of a JSON value
+	pathToken struct {
+		key   string
+		index int // -1 for the object key
+	}
+	// decoderFunc decode the next JSON value into the compiled type that p points to
+	decoderFunc   func(d *decodeState, p unsafe.Pointer) error
+	structDecoder struct {
+		typ    reflect.Type
+		fields []decField
+		index  map[string]int // exact JSON name to the index of fields
+	}
+	decField struct {
+		field
+		dec decoderFunc
+		// hidden the unexported embedded pointers on the way to the promoted field, from the outermost
+		hidden []embeddedPtr
+	}
+	embeddedPtr struct {
+		holder *gofield.Handle // the embedded struct holding the pointer, nil for the top-level struct
+		offset uintptr
+	}
+)
+
+var (
+	decoderCache       sync.Map // key is reflect.Type, value is decoderFunc
+	decodeStatePool    sync.Pool
+	unmarshalerType    = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerTyp = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Unmarshal parse the JSON-encoded data and store the result in the value pointed to by v,
+// which is the same as encoding/json.
+// NOTE:
+//  The fast path is taken only when v is a struct pointer, other values are handed over to encoding/json;
+//  The nil pointer fields are initialized on the way to the assigned fields,
+//  except the pointers to the unexported embedded structs, which are reported as errors by encoding/json;
+//  The type errors name the top-level type and the JSON path of the value, e.g. Outer.Items.1.name,
+//  as encoding/json of Go 1.27 does, while the earlier versions name the innermost struct instead
+func Unmarshal(data []byte, v interface{}) error {
+	return unmarshal(data, v, false, false)
+}
+
+// NewDecoder return a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r)}
+}
+
+// UseNumber cause the decoder to unmarshal a number into an interface{} as a json.Number instead of as a float64.
+func (dec *Decoder) UseNumber() {
+	dec.useNumber = true
+	dec.dec.UseNumber()
+}
+
+// DisallowUnknownFields cause the decoder to return an error when the destination is a struct
+// and the input contains object keys which do not match any non-ignored, exported fields in the destination.
+func (dec *Decoder) DisallowUnknownFields() {
+	dec.disallowUnknownFields = true
+	dec.dec.DisallowUnknownFields()
+}
+
+// More report whether there is another element in the current array or object being parsed.
+func (dec *Decoder) More() bool {
+	return dec.dec.More()
+}
+
+// Buffered return a reader of the data remaining in the decoder's buffer.
+func (dec *Decoder) Buffered() io.Reader {
+	return dec.dec.Buffered()
+}
+
+// Decode read the next JSON-encoded value from its input and store it in the value pointed to by v.
+func (dec *Decoder) Decode(v interface{}) error {
+	if !isStructPtr(v) {
+		return dec.dec.Decode(v)
+	}
+	var raw json.RawMessage
+	if err := dec.dec.Decode(&raw); err != nil {
+		return err
+	}
+	return unmarshal(raw, v, dec.useNumber, dec.disallowUnknownFields)
+}
+
+func isStructPtr(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Struct
+}
+
+func unmarshal(data []byte, v interface{}, useNumber, disallowUnknownFields bool) error {
+	// syntax errors are reported by encoding/json, and nothing is written as it does
+	if !isStructPtr(v) || !json.Valid(data) {
+		return stdUnmarshal(data, v, useNumber, disallowUnknownFields)
+	}
+	d := newDecodeState(data, useNumber, disallowUnknownFields)
+	defer decodeStatePool.Put(d)
+	rv := reflect.ValueOf(v)
+	d.errStruct = rv.Type().Elem().Name()
+	if err := typeDecoder(rv.Type().Elem())(d, unsafe.Pointer(rv.Pointer())); err != nil {
+		return err
+	}
+	return d.savedError
+}
+
+func stdUnmarshal(data []byte, v interface{}, useNumber, disallowUnknownFields bool) error {
+	if !useNumber && !disallowUnknownFields {
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if useNumber {
+		dec.UseNumber()
+	}
+	if disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+func newDecodeState(data []byte, useNumber, disallowUnknownFields bool) *decodeState {
+	d, ok := decodeStatePool.Get().(*decodeState)
+	if !ok {
+		d = &decodeState{}
+	}
+	d.data = data
+	d.off = 0
+	d.useNumber = useNumber
+	d.disallowUnknownFields = disallowUnknownFields
+	d.savedError = nil
+	d.errStruct = ""
+	d.errPath = d.errPath[:0]
+	return d
+}
+
+// typeDecoder return the cached decoder of the type.
+func typeDecoder(t reflect.Type) decoderFunc {
+	if fi, ok := decoderCache.Load(t); ok {
+		return fi.(decoderFunc)
+	}
+	// to deal with the recursive types, store an indirect func before building the decoder
+	var (
+		wg sync.WaitGroup
+		f  decoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := decoderCache.LoadOrStore(t, decoderFunc(func(d *decodeState, p unsafe.Pointer) error {
+		wg.Wait()
+		return f(d, p)
+	}))
+	if loaded {
+		return fi.(decoderFunc)
+	}
+	f = newTypeDecoder(t)
+	wg.Done()
+	decoderCache.Store(t, f)
+	return f
+}
+
+func newTypeDecoder(t reflect.Type) decoderFunc {
+	if reflect.PtrTo(t).Implements(unmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerTyp) {
+		return fallbackDecoder(t)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolDecoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intDecoder(t)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintDecoder(t)
+	case reflect.Float32, reflect.Float64:
+		return floatDecoder(t)
+	case reflect.String:
+		if t == numberType {
+			return numberDecoder
+		}
+		return stringDecoder(t)
+	case reflect.Struct:
+		return newStructDecoder(t)
+	case reflect.Ptr:
+		return newPtrDecoder(t)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// base64 bytes
+			return fallbackDecoder(t)
+		}
+		return newSliceDecoder(t)
+	case reflect.Array:
+		return newArrayDecoder(t)
+	default:
+		return fallbackDecoder(t)
+	}
+}
+
+// fallbackDecoder hand the next value over to encoding/json.
+// NOTE:
+//  The value is passed by pointer, so the methods of pointer receiver take effect as addressable values
+func fallbackDecoder(t reflect.Type) decoderFunc {
+	return func(d *decodeState, p unsafe.Pointer) error {
+		start := d.off
+		d.skipValue()
+		err := stdUnmarshal(d.data[start:d.off], reflect.NewAt(t, p).Interface(), d.useNumber, d.disallowUnknownFields)
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			d.saveError(d.rebaseError(err, d.errPath, start))
+			return nil
+		}
+		return err
+	}
+}
+
+// quotedDecoder decode the JSON string that holds the literal of the value, for the ,string option.
+// NOTE:
+//  t is the field type, which may be an unnamed pointer type
+func quotedDecoder(t reflect.Type, dec decoderFunc) decoderFunc {
+	elemTyp := t
+	if t.Kind() == reflect.Ptr {
+		elemTyp = t.Elem()
+	}
+	// initialize the pointer as encoding/json does before reporting the error
+	initPtr := func(p unsafe.Pointer) {
+		if t.Kind() == reflect.Ptr && *(*unsafe.Pointer)(p) == nil {
+			*(*unsafe.Pointer)(p) = reflect.New(elemTyp).UnsafePointer()
+		}
+	}
+	return func(d *decodeState, p unsafe.Pointer) error {
+		switch d.peek() {
+		case 'n':
+			d.off += len("null")
+			return nil
+		case '"':
+		default:
+			initPtr(p)
+			what := d.valueKind()
+			d.skipValue()
+			d.saveTypeError(what, elemTyp)
+			return nil
+		}
+		s := d.readString()
+		ok := len(s) > 0 && len(bytes.TrimSpace(s)) == len(s) && json.Valid(s)
+		if ok && elemTyp.Kind() == reflect.String {
+			ok = s[0] == '"'
+		} else if ok {
+			ok = s[0] != '"' && s[0] != '{' && s[0] != '['
+		}
+		if !ok {
+			initPtr(p)
+			if elemTyp.Kind() == reflect.String {
+				d.saveTypeError("string", elemTyp)
+			} else {
+				d.saveTypeError("number "+string(s), elemTyp)
+			}
+			return nil
+		}
+		inner := newDecodeState(append([]byte(nil), s...), d.useNumber, d.disallowUnknownFields)
+		defer decodeStatePool.Put(inner)
+		inner.errStruct, inner.errPath = d.errStruct, append(inner.errPath, d.errPath...)
+		if err := dec(inner, p); err != nil {
+			return err
+		}
+		if e, ok := inner.savedError.(*json.UnmarshalTypeError); ok {
+			// the offset of the quoted string as encoding/json reports
+			e.Offset = int64(d.off)
+		}
+		if inner.savedError != nil {
+			d.saveError(inner.savedError)
+		}
+		return nil
+	}
+}
+
+// saveError save the first type error, which is returned after the whole value is decoded.
+func (d *decodeState) saveError(err error) {
+	if d.savedError == nil {
+		d.savedError = err
+	}
+}
+
+// typeError skip the next value and save the type error of it.
+// NOTE:
+//  what is the kind of the value only as encoding/json reports, the literals of the invalid numbers are reported by the callers
+func (d *decodeState) typeError(what string, t reflect.Type) {
+	start := d.off
+	d.skipValue()
+	if what == "object" || what == "array" {
+		// the offset following the opening delimiter as encoding/json reports
+		d.saveTypeErrorAt(what, t, start+1)
+		return
+	}
+	d.saveTypeError(what, t)
+}
+
+// saveTypeError save the type error of the value just read.
+func (d *decodeState) saveTypeError(what string, t reflect.Type) {
+	d.saveTypeErrorAt(what, t, d.off)
+}
+
+func (d *decodeState) saveTypeErrorAt(what string, t reflect.Type, offset int) {
+	if d.savedError != nil {
+		return
+	}
+	err := &json.UnmarshalTypeError{Value: what, Type: t, Offset: int64(offset)}
+	if len(d.errPath) > 0 {
+		err.Struct, err.Field = d.errStruct, formatPath(d.errPath)
+	}
+	d.saveError(err)
+}
+
+// rebaseError rebase the type error reported by encoding/json for the value at path and offset,
+// to be relative to the top-level value.
+func (d *decodeState) rebaseError(err error, path []pathToken, offset int) error {
+	e, ok := err.(*json.UnmarshalTypeError)
+	if !ok {
+		return err
+	}
+	e.Offset += int64(offset)
+	if len(path) > 0 {
+		field := formatPath(path)
+		if e.Field != "" {
+			field += "." + e.Field
+		}
+		e.Struct, e.Field = d.errStruct, field
+	}
+	return e
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// formatPath format the path as encoding/json does, which is the JSON pointer delimited by '.'.
+func formatPath(path []pathToken) string {
+	var b strings.Builder
+	for i, tok := range path {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		if tok.index >= 0 {
+			b.WriteString(strconv.Itoa(tok.index))
+		} else {
+			b.WriteString(pointerEscaper.Replace(tok.key))
+		}
+	}
+	return b.String()
+}
+
+// valueKind return the name of the next value kind, which is used by the type errors.
+func (d *decodeState) valueKind() string {
+	switch d.peek() {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "bool"
+	default:
+		return "number"
+	}
+}
+
+func boolDecoder(d *decodeState, p unsafe.Pointer) error {
+	switch d.peek() {
+	case 't':
+		*(*bool)(p) = true
+		d.off += len("true")
+	case 'f':
+		*(*bool)(p) = false
+		d.off += len("false")
+	case 'n':
+		d.off += len("null")
+	default:
+		d.typeError(d.valueKind(), reflect.TypeOf(false))
+	}
+	return nil
+}
+
+func intDecoder(t reflect.Type) decoderFunc {
+	bits := int(t.Size()) * 8
+	return func(d *decodeState, p unsafe.Pointer) error {
+		switch c := d.peek(); {
+		case c == 'n':
+			d.off += len("null")
+			return nil
+		case c != '-' && !isDigit(c):
+			d.typeError(d.valueKind(), t)
+			return nil
+		}
+		lit := d.readNumber()
+		n, err := strconv.ParseInt(ameda.UnsafeBytesToString(lit), 10, 64)
+		if err != nil || bits < 64 && (n < -1<<(bits-1) || n >= 1<<(bits-1)) {
+			d.saveTypeError("number "+string(lit), t)
+			return nil
+		}
+		switch bits {
+		case 8:
+			*(*int8)(p) = int8(n)
+		case 16:
+			*(*int16)(p) = int16(n)
+		case 32:
+			*(*int32)(p) = int32(n)
+		default:
+			*(*int64)(p) = n
+		}
+		return nil
+	}
+}
+
+func uintDecoder(t reflect.Type) decoderFunc {
+	bits := int(t.Size()) * 8
+	return func(d *decodeState, p unsafe.Pointer) error {
+		switch c := d.peek(); {
+		case c == 'n':
+			d.off += len("null")
+			return nil
+		case c != '-' && !isDigit(c):
+			d.typeError(d.valueKind(), t)
+			return nil
+		}
+		lit := d.readNumber()
+		n, err := strconv.ParseUint(ameda.UnsafeBytesToString(lit), 10, 64)
+		if err != nil || bits < 64 && n >= 1<<bits {
+			d.saveTypeError("number "+string(lit), t)
+			return nil
+		}
+		switch bits {
+		case 8:
+			*(*uint8)(p) = uint8(n)
+		case 16:
+			*(*uint16)(p) = uint16(n)
+		case 32:
+			*(*uint32)(p) = uint32(n)
+		default:
+			*(*uint64)(p) = n
+		}
+		return nil
+	}
+}
+
+func floatDecoder(t reflect.Type) decoderFunc {
+	bits := int(t.Size()) * 8
+	return func(d *decodeState, p unsafe.Pointer) error {
+		switch c := d.peek(); {
+		case c == 'n':
+			d.off += len("null")
+			return nil
+		case c != '-' && !isDigit(c):
+			d.typeError(d.valueKind(), t)
+			return nil
+		}
+		lit := d.readNumber()
+		f, err := strconv.ParseFloat(ameda.UnsafeBytesToString(lit), bits)
+		if err != nil {
+			d.saveTypeError("number "+string(lit), t)
+			return nil
+		}
+		if bits == 32 {
+			*(*float32)(p) = float32(f)
+		} else {
+			*(*float64)(p) = f
+		}
+		return nil
+	}
+}
+
+func stringDecoder(t reflect.Type) decoderFunc {
+	return func(d *decodeState, p unsafe.Pointer) error {
+		switch d.peek() {
+		case '"':
+			*(*string)(p) = string(d.readString())
+		case 'n':
+			d.off += len("null")
+		default:
+			d.typeError(d.valueKind(), t)
+		}
+		return nil
+	}
+}
+
+func numberDecoder(d *decodeState, p unsafe.Pointer) error {
+	switch c := d.peek(); {
+	case c == 'n':
+		d.off += len("null")
+	case c == '"':
+		start := d.off
+		s := string(d.readString())
+		if s != "" && !isValidNumber(s) {
+			// the error differs between the encoding/json versions, and is reported without the context
+			if d.savedError == nil {
+				d.saveError(stdUnmarshal(d.data[start:d.off], new(json.Number), false, false))
+			}
+			return nil
+		}
+		*(*string)(p) = s
+	case c == '-' || isDigit(c):
+		*(*string)(p) = string(d.readNumber())
+	default:
+		d.typeError(d.valueKind(), numberType)
+	}
+	return nil
+}
+
+func newStructDecoder(t reflect.Type) decoderFunc {
+	fields := typeFields(t)
+	sd := &structDecoder{
+		typ:    t,
+		fields: make([]decField, len(fields)),
+		index:  make(map[string]int, len(fields)),
+	}
+	st := accessor.MustAnalyze(reflect.New(t))
+	for i, f := range fields {
+		df := decField{field: f, dec: typeDecoder(f.node.Type), hidden: hiddenPtrs(st, f.node)}
+		if f.quoted {
+			df.dec = quotedDecoder(f.node.Type, newTypeDecoder(f.node.Type))
+		}
+		sd.fields[i] = df
+		sd.index[f.name] = i
+	}
+	return sd.decode
+}
+
+// hiddenPtrs return the unexported embedded pointers on the way to the field, from the outermost.
+func hiddenPtrs(st *gofield.StructType, node *gofield.FieldType) []embeddedPtr {
+	var hidden []embeddedPtr
+	for n := node.Parent(); n != nil; n = n.Parent() {
+		if !n.Anonymous || n.IsExported() || n.Type.Kind() != reflect.Ptr {
+			continue
+		}
+		e := embeddedPtr{offset: n.Offset}
+		if holder := n.Parent(); holder != nil {
+			e.holder, _ = st.Handle(holder.ID())
+		}
+		hidden = append([]embeddedPtr{e}, hidden...)
+	}
+	return hidden
+}
+
+// lookup return the field matching the key, preferring an exact match over a case-insensitive match.
+func (sd *structDecoder) lookup(key []byte) *decField {
+	if i, ok := sd.index[ameda.UnsafeBytesToString(key)]; ok {
+		return &sd.fields[i]
+	}
+	name := ameda.UnsafeBytesToString(key)
+	for i := range sd.fields {
+		if strings.EqualFold(sd.fields[i].name, name) {
+			return &sd.fields[i]
+		}
+	}
+	return nil
+}
+
+func (sd *structDecoder) decode(d *decodeState, p unsafe.Pointer) error {
+	switch d.peek() {
+	case '{':
+	case 'n':
+		d.off += len("null")
+		return nil
+	default:
+		d.typeError(d.valueKind(), sd.typ)
+		return nil
+	}
+	d.off++
+	pathLen := len(d.errPath)
+	defer func() { d.errPath = d.errPath[:pathLen] }()
+	for {
+		if d.peek() == '}' {
+			d.off++
+			return nil
+		}
+		key := d.readString()
+		f := sd.lookup(key)
+		if f == nil && d.disallowUnknownFields {
+			d.saveError(fmt.Errorf("json: unknown field %q", key))
+		}
+		d.peek() // skip ':'
+		d.off++
+		if f == nil {
+			d.skipValue()
+		} else {
+			d.errPath = append(d.errPath[:pathLen], pathToken{key: f.name, index: -1})
+			if string(key) != f.name {
+				// the path holds the key as written, which matches the name case-insensitively
+				d.errPath[pathLen].key = string(key)
+			}
+			if !f.settable(p) {
+				d.embeddedPtrError(sd.typ, key)
+			} else if err := f.dec(d, f.ptr(p)); err != nil {
+				return err
+			}
+		}
+		if d.peek() == ',' {
+			d.off++
+		}
+	}
+}
+
+// settable report whether the unexported embedded pointers on the way to the field are not nil,
+// which can not be initialized as encoding/json does.
+func (f *decField) settable(p unsafe.Pointer) bool {
+	for _, e := range f.hidden {
+		hp := p
+		if e.holder != nil {
+			hp = e.holder.Ptr(p)
+		}
+		if *(*unsafe.Pointer)(unsafe.Add(hp, e.offset)) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ptr return the field pointer in the struct that p points to.
+// NOTE:
+//  The nil embedded pointers on the way to the field are initialized
+func (f *decField) ptr(p unsafe.Pointer) unsafe.Pointer {
+	if f.parent != nil {
+		p = f.parent.Ptr(p)
+	}
+	return unsafe.Add(p, f.node.Offset)
+}
+
+// embeddedPtrError skip the next value of the key, and save the error that encoding/json reports for it
+// when the field is promoted through a nil pointer to an unexported struct.
+// NOTE:
+//  The error depends on the encoding/json version, so it is reported by encoding/json itself
+//  decoding the member into a zero value of the struct type
+func (d *decodeState) embeddedPtrError(t reflect.Type, key []byte) {
+	if d.savedError != nil {
+		d.skipValue()
+		return
+	}
+	member := append(appendString([]byte{'{'}, string(key), false), ':')
+	start := d.off
+	d.skipValue()
+	offset := start - len(member)
+	member = append(append(member, d.data[start:d.off]...), '}')
+	err := stdUnmarshal(member, reflect.New(t).Interface(), d.useNumber, d.disallowUnknownFields)
+	d.saveError(d.rebaseError(err, d.errPath[:len(d.errPath)-1], offset))
+}
+
+func newPtrDecoder(t reflect.Type) decoderFunc {
+	elemTyp := t.Elem()
+	elemDec := typeDecoder(elemTyp)
+	return func(d *decodeState, p unsafe.Pointer) error {
+		pp := (*unsafe.Pointer)(p)
+		if d.peek() == 'n' {
+			d.off += len("null")
+			*pp = nil
+			return nil
+		}
+		if *pp == nil {
+			*pp = reflect.New(elemTyp).UnsafePointer()
+		}
+		return elemDec(d, *pp)
+	}
+}
+
+func newSliceDecoder(t reflect.Type) decoderFunc {
+	elemTyp := t.Elem()
+	elemDec := typeDecoder(elemTyp)
+	elemSize := elemTyp.Size()
+	return func(d *decodeState, p unsafe.Pointer) error {
+		sh := (*sliceHeader)(p)
+		switch d.peek() {
+		case '[':
+		case 'n':
+			d.off += len("null")
+			*sh = sliceHeader{}
+			return nil
+		default:
+			d.typeError(d.valueKind(), t)
+			return nil
+		}
+		d.off++
+		pathLen := len(d.errPath)
+		defer func() { d.errPath = d.errPath[:pathLen] }()
+		i := 0
+		for d.peek() != ']' {
+			if i >= sh.cap {
+				sv := reflect.NewAt(t, p).Elem()
+				newCap := sv.Cap() * 2
+				if newCap < 4 {
+					newCap = 4
+				}
+				ns := reflect.MakeSlice(t, sv.Len(), newCap)
+				reflect.Copy(ns, sv)
+				sv.Set(ns)
+			}
+			if i >= sh.len {
+				sh.len = i + 1
+			}
+			d.errPath = append(d.errPath[:pathLen], pathToken{index: i})
+			if err := elemDec(d, unsafe.Add(sh.data, uintptr(i)*elemSize)); err != nil {
+				return err
+			}
+			i++
+			if d.peek() == ',' {
+				d.off++
+			}
+		}
+		d.off++
+		if i == 0 && sh.data == nil {
+			reflect.NewAt(t, p).Elem().Set(reflect.MakeSlice(t, 0, 0))
+		}
+		sh.len = i
+		return nil
+	}
+}
+
+func newArrayDecoder(t reflect.Type) decoderFunc {
+	elemTyp := t.Elem()
+	elemDec := typeDecoder(elemTyp)
+	elemSize := elemTyp.Size()
+	zero := reflect.Zero(elemTyp)
+	n := t.Len()
+	return func(d *decodeState, p unsafe.Pointer) error {
+		switch d.peek() {
+		case '[':
+		case 'n':
+			d.off += len("null")
+			return nil
+		default:
+			d.typeError(d.valueKind(), t)
+			return nil
+		}
+		d.off++
+		pathLen := len(d.errPath)
+		defer func() { d.errPath = d.errPath[:pathLen] }()
+		i := 0
+		for ; d.peek() != ']'; i++ {
+			if i < n {
+				d.errPath = append(d.errPath[:pathLen], pathToken{index: i})
+				if err := elemDec(d, unsafe.Add(p, uintptr(i)*elemSize)); err != nil {
+					return err
+				}
+			} else {
+				d.skipValue()
+			}
+			if d.peek() == ',' {
+				d.off++
+			}
+		}
+		d.off++
+		for ; i < n; i++ {
+			reflect.NewAt(elemTyp, unsafe.Add(p, uintptr(i)*elemSize)).Elem().Set(zero)
+		}
+		return nil
+	}
+}
+
+// peek skip the white spaces and return the next byte.
+// NOTE:
+//  The data has been validated, so the next byte always exists where a value or delimiter is expected
+func (d *decodeState) peek() byte {
+	for ; d.off < len(d.data); d.off++ {
+		switch c := d.data[d.off]; c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c
+		}
+	}
+	return 0
+}
+
+// skipValue skip the next value.
+func (d *decodeState) skipValue() {
+	switch d.peek() {
+	case '"':
+		d.skipString()
+	case '{', '[':
+		depth := 0
+		for d.off < len(d.data) {
+			switch d.data[d.off] {
+			case '"':
+				d.skipString()
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					d.off++
+					return
+				}
+			}
+			d.off++
+		}
+	case 't', 'n':
+		d.off += len("true")
+	case 'f':
+		d.off += len("false")
+	default:
+		d.readNumber()
+	}
+}
+
+func (d *decodeState) skipString() {
+	for i := d.off + 1; i < len(d.data); i++ {
+		switch d.data[i] {
+		case '\\':
+			i++
+		case '"':
+			d.off = i + 1
+			return
+		}
+	}
+	d.off = len(d.data)
+}
+
+// readNumber read the next number literal.
+func (d *decodeState) readNumber() []byte {
+	start := d.off
+	for ; d.off < len(d.data); d.off++ {
+		switch c := d.data[d.off]; {
+		case isDigit(c), c == '-', c == '+', c == '.', c == 'e', c == 'E':
+		default:
+			return d.data[start:d.off]
+		}
+	}
+	return d.data[start:]
+}
+
+// readString read the next string and return the unquoted content.
+// NOTE:
+//  The content may be invalidated by the next call, since it refers to the data or the scratch buffer
+func (d *decodeState) readString() []byte {
+	start := d.off + 1
+	for i := start; i < len(d.data); i++ {
+		switch c := d.data[i]; {
+		case c == '"':
+			d.off = i + 1
+			return d.data[start:i]
+		case c == '\\' || c >= utf8.RuneSelf:
+			d.skipString()
+			d.scratch = unquoteBytes(d.scratch[:0], d.data[start:d.off-1])
+			return d.scratch
+		}
+	}
+	d.off = len(d.data)
+	return nil
+}
+
+// unquoteBytes append the unescaped string content to dst,
+// replacing the invalid UTF-8 and surrogates with utf8.RuneError as encoding/json does.
+func unquoteBytes(dst, s []byte) []byte {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+			switch s[i] {
+			case 'b':
+				dst = append(dst, '\b')
+			case 'f':
+				dst = append(dst, '\f')
+			case 'n':
+				dst = append(dst, '\n')
+			case 'r':
+				dst = append(dst, '\r')
+			case 't':
+				dst = append(dst, '\t')
+			case 'u':
+				r := getu4(s[i+1:])
+				i += 4
+				if utf16.IsSurrogate(r) {
+					r2 := rune(-1)
+					if i+2 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+						r2 = getu4(s[i+3:])
+					}
+					if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+						i += 6
+						r = dec
+					} else {
+						r = utf8.RuneError
+					}
+				}
+				dst = utf8.AppendRune(dst, r)
+			default: // '"', '\\' and '/'
+				dst = append(dst, s[i])
+			}
+			i++
+		case c < utf8.RuneSelf:
+			dst = append(dst, c)
+			i++
+		default:
+			r, size := utf8.DecodeRune(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				dst = utf8.AppendRune(dst, utf8.RuneError)
+			} else {
+				dst = append(dst, s[i:i+size]...)
+			}
+			i += size
+		}
+	}
+	return dst
+}
+
+// getu4 decode the 4 hex digits to a rune, return -1 if failed.
+func getu4(s []byte) rune {
+	if len(s) < 4 {
+		return -1
+	}
+	r, err := strconv.ParseUint(ameda.UnsafeBytesToString(s[:4]), 16, 32)
+	if err != nil {
+		return -1
+	}
+	return rune(r)
+}
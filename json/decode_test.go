@@ -0,0 +1,171 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json_test
+
+import (
+	stdjson "encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/henrylee2cn/gofield/json"
+)
+
+type (
+	Upper   string
+	Request struct {
+		ID      int64
+		Name    string `json:"user_name"`
+		Tags    []string
+		Limit   *int  `json:",string"`
+		Note    Upper `json:"note"`
+		Extra   interface{}
+		Nested  *P1
+		Numbers [3]uint8
+		Num     stdjson.Number
+	}
+	hiddenEmb struct{ Y int }
+	HiddenPtr struct {
+		*hiddenEmb
+		X int
+	}
+)
+
+func (u *Upper) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := stdjson.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*u = Upper(strings.ToUpper(s))
+	return nil
+}
+
+// assertUnmarshal decode data into the new values of typ by encoding/json and gofield/json, then compare them.
+func assertUnmarshal(t *testing.T, typ reflect.Type, data string, msgAndArgs ...interface{}) {
+	want, got := reflect.New(typ), reflect.New(typ)
+	wantErr := stdjson.Unmarshal([]byte(data), want.Interface())
+	err := json.Unmarshal([]byte(data), got.Interface())
+	// including the context of the type errors
+	assert.Equal(t, wantErr, err, msgAndArgs...)
+	assert.Equal(t, want.Interface(), got.Interface(), msgAndArgs...)
+}
+
+func TestUnmarshalGolden(t *testing.T) {
+	for i, v := range goldenCorpus() {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		data, err := stdjson.Marshal(v)
+		assert.NoError(t, err)
+		assertUnmarshal(t, rv.Type().Elem(), string(data), "case %d", i)
+	}
+}
+
+func TestUnmarshalCompatible(t *testing.T) {
+	var cases = []struct {
+		typ  reflect.Type
+		data string
+	}{
+		{reflect.TypeOf(Request{}), `{"id": 1, "USER_NAME": "n", "tags": ["a", "b"], "limit": "10", "NOTE": "x", "extra": {"k": [1, 2.5]}, "nested": {"e": 5, "c": 3}, "numbers": [1, 2, 3, 4], "num": 1.50}`},
+		{reflect.TypeOf(Request{}), `{"unknown": {"a": [1, {"b": "]}"}]}, "ID": -9223372036854775808, "Numbers": [1]}`},
+		{reflect.TypeOf(Request{}), `{"ID": null, "Name": null, "Tags": null, "Limit": null, "Nested": null, "Num": "2"}`},
+		{reflect.TypeOf(Request{}), `{"ID": "1", "Tags": "x", "Numbers": [256], "user_name": "after the type errors"}`},
+		{reflect.TypeOf(Request{}), `{"ID": 1.5}`},
+		{reflect.TypeOf(Request{}), `{"Limit": 10}`},
+		{reflect.TypeOf(Request{}), `{"Limit": "ten"}`},
+		{reflect.TypeOf(Request{}), `{"Num": "ten"}`},
+		{reflect.TypeOf(Request{}), `{"ID": 1,}`},
+		{reflect.TypeOf(Request{}), `{"ID": 1} trailing`},
+		{reflect.TypeOf(Request{}), `[]`},
+		{reflect.TypeOf(Request{}), `null`},
+		{reflect.TypeOf(Basic{}), `{"String": "é😀 \ud800 \"\\\/\b\f\n\r\t \xff", "Bytes": "Ynl0ZXM=", "Int8": 128, "Uint": -1, "Float32": 1e40}`},
+		{reflect.TypeOf(Basic{}), `{"bool": true, "int": 1e2, "uint8": 255, "float64": -1.5e-3, "named": 36.6}`},
+		{reflect.TypeOf(Tagged{}), `{"renamed": "r", "Ignored": "i", "-": "d", "x-y.z": "p", "IntStr": "1", "BoolStr": "true", "FloatStr": "1.5", "StrStr": "\"s\"", "PtrStr": "2", "SliceStr": [1]}`},
+		{reflect.TypeOf(Lvl01{}), `{"Leaf": 3}`},
+		{reflect.TypeOf(Embedded{}), `{"Outer": "o", "A": "a", "B": "b", "Hidden": "h", "Same": "s", "tag": "t", "Tagged": "deep", "Shadowed": "t1"}`},
+		{reflect.TypeOf(Container{}), `{"Items": [{"name": "a", "price": 1}], "Ptrs": [null, {}], "Empty": [], "Array": [{"Name": "x"}], "Matrix": [[1], null, []], "Map": {"k": {"Name": "v"}}}`},
+		{reflect.TypeOf(Node{}), `{"Val": 1, "Next": {"Val": 2, "Next": {"Val": 3}}}`},
+		{reflect.TypeOf(Container{}), `{"Items": [{"Name": "a"}, {"Name": 1}], "Map": {"k": {"Name": 2}}}`},
+		{reflect.TypeOf(Container{}), `{"Map": {"a/b~c": {"Name": 2}}}`},
+		{reflect.TypeOf(Container{}), `{"Array": [{}, {"NAME": []}]}`},
+		{reflect.TypeOf(Node{}), `{"Next": {"Next": {"Val": "3"}}}`},
+		// the nil pointer to the unexported embedded struct can not be initialized
+		{reflect.TypeOf(HiddenPtr{}), `{"Y": 2, "X": 1}`},
+		{reflect.TypeOf(HiddenPtr{}), `{"X": 1, "y": {"a": 1}}`},
+	}
+	for i, c := range cases {
+		assertUnmarshal(t, c.typ, c.data, "case %d: %s", i, c.data)
+	}
+}
+
+func TestUnmarshalInto(t *testing.T) {
+	// the values are merged into the existing ones, and the nil pointers are initialized on the way
+	one := 1
+	r := &Request{Name: "keep", Limit: &one, Tags: make([]string, 3, 8), Nested: &P1{A: 1}}
+	tags := r.Tags
+	assert.NoError(t, json.Unmarshal([]byte(`{"Limit": "2", "Tags": ["x"], "Nested": {"E": 5}}`), r))
+	assert.Equal(t, "keep", r.Name)
+	assert.Equal(t, 2, one)
+	assert.Equal(t, []string{"x"}, r.Tags)
+	assert.Equal(t, &tags[0], &r.Tags[0])
+	assert.Equal(t, 1, r.Nested.A)
+	assert.Equal(t, 5, r.Nested.P3.E)
+}
+
+func TestDecoder(t *testing.T) {
+	const stream = `{"ID": 1, "Extra": 12345678901234567890} {"ID": 2, "Unknown": true, "user_name": "n"}`
+	dec := json.NewDecoder(strings.NewReader(stream))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	var r Request
+	assert.NoError(t, dec.Decode(&r))
+	assert.Equal(t, int64(1), r.ID)
+	assert.Equal(t, stdjson.Number("12345678901234567890"), r.Extra)
+	assert.True(t, dec.More())
+	r = Request{}
+	assert.EqualError(t, dec.Decode(&r), `json: unknown field "Unknown"`)
+	assert.Equal(t, Request{ID: 2, Name: "n"}, r)
+	assert.False(t, dec.More())
+
+	var m map[string]int
+	dec = json.NewDecoder(strings.NewReader(`{"a": 1}`))
+	assert.NoError(t, dec.Decode(&m))
+	assert.Equal(t, map[string]int{"a": 1}, m)
+}
+
+func BenchmarkUnmarshal_Gofield(b *testing.B) {
+	b.ReportAllocs()
+	p, _ := stdjson.Marshal(&P1{A: 1, P2: P2{C: 3, P3: &P3{E: 5}}})
+	c, _ := stdjson.Marshal(benchValue())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = json.Unmarshal(p, new(P1))
+		_ = json.Unmarshal(c, new(Container))
+	}
+}
+
+func BenchmarkUnmarshal_Std(b *testing.B) {
+	b.ReportAllocs()
+	p, _ := stdjson.Marshal(&P1{A: 1, P2: P2{C: 3, P3: &P3{E: 5}}})
+	c, _ := stdjson.Marshal(benchValue())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = stdjson.Unmarshal(p, new(P1))
+		_ = stdjson.Unmarshal(c, new(Container))
+	}
+}
@@ -0,0 +1,630 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+	"unsafe"
+)
+
+type (
+	// Encoder write JSON values to an output stream
+	Encoder struct {
+		w io.Writer
+	}
+	encodeState struct {
+		buf      []byte
+		ptrLevel uint
+	}
+	// encoderFunc encode the value of the compiled type that p points to
+	encoderFunc   func(e *encodeState, p unsafe.Pointer) error
+	structEncoder struct {
+		fields []encField
+	}
+	encField struct {
+		field
+		enc    encoderFunc
+		isZero func(p unsafe.Pointer) bool // for omitempty or omitzero, nil if not needed
+	}
+	sliceHeader struct {
+		data unsafe.Pointer
+		len  int
+		cap  int
+	}
+)
+
+// the pointer depth to hand over to encoding/json which detects the cycles
+const startDetectingCyclesAfter = 1000
+
+var (
+	encoderCache     sync.Map // key is reflect.Type, value is encoderFunc
+	encodeStatePool  sync.Pool
+	marshalerType    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerTyp = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	isZeroerType     = reflect.TypeOf((*interface{ IsZero() bool })(nil)).Elem()
+	numberType       = reflect.TypeOf(json.Number(""))
+	// the escapes of \b, \f and invalid UTF-8, and the omitzero option depend on the encoding/json version
+	escapeBF = func() bool {
+		b, _ := json.Marshal("\b")
+		return string(b) == `"\b"`
+	}()
+	invalidRune = func() string {
+		b, _ := json.Marshal("\xff")
+		return string(b[1 : len(b)-1])
+	}()
+	supportOmitZero = func() bool {
+		b, _ := json.Marshal(struct {
+			A int `json:",omitzero"`
+		}{})
+		return string(b) == "{}"
+	}()
+)
+
+// Marshal return the JSON encoding of v, which is the same as encoding/json.
+// NOTE:
+//  The fast path is taken only when v is a struct pointer, other values are handed over to encoding/json
+func Marshal(v interface{}) ([]byte, error) {
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+	if err := e.marshal(v); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.buf...), nil
+}
+
+// NewEncoder return a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode write the JSON encoding of v to the stream, followed by a newline character.
+func (enc *Encoder) Encode(v interface{}) error {
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+	if err := e.marshal(v); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, '\n')
+	_, err := enc.w.Write(e.buf)
+	return err
+}
+
+func newEncodeState() *encodeState {
+	if e, ok := encodeStatePool.Get().(*encodeState); ok {
+		e.buf = e.buf[:0]
+		e.ptrLevel = 0
+		return e
+	}
+	return &encodeState{buf: make([]byte, 0, 1024)}
+}
+
+func (e *encodeState) marshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		b, err := json.Marshal(v)
+		e.buf = append(e.buf, b...)
+		return err
+	}
+	return typeEncoder(rv.Type().Elem())(e, unsafe.Pointer(rv.Pointer()))
+}
+
+// typeEncoder return the cached encoder of the type.
+func typeEncoder(t reflect.Type) encoderFunc {
+	if fi, ok := encoderCache.Load(t); ok {
+		return fi.(encoderFunc)
+	}
+	// to deal with the recursive types, store an indirect func before building the encoder
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *encodeState, p unsafe.Pointer) error {
+		wg.Wait()
+		return f(e, p)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+	f = newTypeEncoder(t, false)
+	wg.Done()
+	encoderCache.Store(t, f)
+	return f
+}
+
+func newTypeEncoder(t reflect.Type, quoted bool) encoderFunc {
+	if t.Implements(marshalerType) || t.Implements(textMarshalerTyp) {
+		return fallbackEncoder(t)
+	}
+	if t.Kind() != reflect.Ptr {
+		pt := reflect.PtrTo(t)
+		if pt.Implements(marshalerType) || pt.Implements(textMarshalerTyp) {
+			return fallbackEncoder(t)
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return quote(quoted, boolEncoder)
+	case reflect.Int:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendInt(e.buf, int64(*(*int)(p)), 10)
+			return nil
+		})
+	case reflect.Int8:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendInt(e.buf, int64(*(*int8)(p)), 10)
+			return nil
+		})
+	case reflect.Int16:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendInt(e.buf, int64(*(*int16)(p)), 10)
+			return nil
+		})
+	case reflect.Int32:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendInt(e.buf, int64(*(*int32)(p)), 10)
+			return nil
+		})
+	case reflect.Int64:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendInt(e.buf, *(*int64)(p), 10)
+			return nil
+		})
+	case reflect.Uint:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendUint(e.buf, uint64(*(*uint)(p)), 10)
+			return nil
+		})
+	case reflect.Uint8:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendUint(e.buf, uint64(*(*uint8)(p)), 10)
+			return nil
+		})
+	case reflect.Uint16:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendUint(e.buf, uint64(*(*uint16)(p)), 10)
+			return nil
+		})
+	case reflect.Uint32:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendUint(e.buf, uint64(*(*uint32)(p)), 10)
+			return nil
+		})
+	case reflect.Uint64:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendUint(e.buf, *(*uint64)(p), 10)
+			return nil
+		})
+	case reflect.Uintptr:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			e.buf = strconv.AppendUint(e.buf, uint64(*(*uintptr)(p)), 10)
+			return nil
+		})
+	case reflect.Float32:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			return e.appendFloat(float64(*(*float32)(p)), 32)
+		})
+	case reflect.Float64:
+		return quote(quoted, func(e *encodeState, p unsafe.Pointer) error {
+			return e.appendFloat(*(*float64)(p), 64)
+		})
+	case reflect.String:
+		if t == numberType {
+			return quote(quoted, numberEncoder)
+		}
+		if quoted {
+			return quotedStringEncoder
+		}
+		return stringEncoder
+	case reflect.Struct:
+		return newStructEncoder(t)
+	case reflect.Ptr:
+		return newPtrEncoder(t, quoted)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// base64 bytes
+			return fallbackEncoder(t)
+		}
+		return newSliceEncoder(t)
+	case reflect.Array:
+		return newArrayEncoder(t)
+	default:
+		return fallbackEncoder(t)
+	}
+}
+
+// fallbackEncoder hand the value over to encoding/json.
+// NOTE:
+//  The value is passed by pointer, so the methods of pointer receiver take effect as addressable values
+func fallbackEncoder(t reflect.Type) encoderFunc {
+	return func(e *encodeState, p unsafe.Pointer) error {
+		b, err := json.Marshal(reflect.NewAt(t, p).Interface())
+		if err != nil {
+			return err
+		}
+		e.buf = append(e.buf, b...)
+		return nil
+	}
+}
+
+func quote(quoted bool, enc encoderFunc) encoderFunc {
+	if !quoted {
+		return enc
+	}
+	return func(e *encodeState, p unsafe.Pointer) error {
+		e.buf = append(e.buf, '"')
+		if err := enc(e, p); err != nil {
+			return err
+		}
+		e.buf = append(e.buf, '"')
+		return nil
+	}
+}
+
+func boolEncoder(e *encodeState, p unsafe.Pointer) error {
+	e.buf = strconv.AppendBool(e.buf, *(*bool)(p))
+	return nil
+}
+
+func stringEncoder(e *encodeState, p unsafe.Pointer) error {
+	e.buf = appendString(e.buf, *(*string)(p), true)
+	return nil
+}
+
+func numberEncoder(e *encodeState, p unsafe.Pointer) error {
+	s := *(*string)(p)
+	if s == "" {
+		s = "0" // Number's zero-val
+	}
+	if !isValidNumber(s) {
+		return fmt.Errorf("json: invalid number literal %q", s)
+	}
+	e.buf = append(e.buf, s...)
+	return nil
+}
+
+// isValidNumber reports whether s is a valid JSON number literal.
+func isValidNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
+		}
+	}
+	switch {
+	case s[0] == '0':
+		s = s[1:]
+	case '1' <= s[0] && s[0] <= '9':
+		s = skipDigits(s[1:])
+	default:
+		return false
+	}
+	if len(s) >= 2 && s[0] == '.' && isDigit(s[1]) {
+		s = skipDigits(s[2:])
+	}
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		if !isDigit(s[0]) {
+			return false
+		}
+		s = skipDigits(s[1:])
+	}
+	return s == ""
+}
+
+func skipDigits(s string) string {
+	for s != "" && isDigit(s[0]) {
+		s = s[1:]
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func quotedStringEncoder(e *encodeState, p unsafe.Pointer) error {
+	e.buf = appendString(e.buf, string(appendString(nil, *(*string)(p), true)), false)
+	return nil
+}
+
+func (e *encodeState) appendFloat(f float64, bits int) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return &json.UnsupportedValueError{Value: reflect.ValueOf(f), Str: strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	e.buf = strconv.AppendFloat(e.buf, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(e.buf)
+		if n >= 4 && e.buf[n-4] == 'e' && e.buf[n-3] == '-' && e.buf[n-2] == '0' {
+			e.buf[n-2] = e.buf[n-1]
+			e.buf = e.buf[:n-1]
+		}
+	}
+	return nil
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+	fields := typeFields(t)
+	se := &structEncoder{fields: make([]encField, len(fields))}
+	for i, f := range fields {
+		ef := encField{field: f}
+		if f.quoted {
+			ef.enc = newTypeEncoder(f.node.Type, true)
+		} else {
+			ef.enc = typeEncoder(f.node.Type)
+		}
+		switch {
+		case f.omitEmpty && f.omitZero:
+			empty, zero := emptyFunc(f.node.Type), zeroFunc(f.node.Type)
+			ef.isZero = func(p unsafe.Pointer) bool { return empty(p) || zero(p) }
+		case f.omitEmpty:
+			ef.isZero = emptyFunc(f.node.Type)
+		case f.omitZero:
+			ef.isZero = zeroFunc(f.node.Type)
+		}
+		se.fields[i] = ef
+	}
+	return se.encode
+}
+
+func (se *structEncoder) encode(e *encodeState, p unsafe.Pointer) error {
+	e.buf = append(e.buf, '{')
+	first := true
+	for i := range se.fields {
+		f := &se.fields[i]
+		fp := p
+		if f.parent != nil {
+			var ok bool
+			fp, ok = f.parent.Lookup(p)
+			if !ok {
+				// the embedded struct pointer is nil
+				continue
+			}
+		}
+		fp = unsafe.Add(fp, f.node.Offset)
+		if f.isZero != nil && f.isZero(fp) {
+			continue
+		}
+		if first {
+			first = false
+		} else {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = append(e.buf, f.nameBytes...)
+		if err := f.enc(e, fp); err != nil {
+			return err
+		}
+	}
+	e.buf = append(e.buf, '}')
+	return nil
+}
+
+func newPtrEncoder(t reflect.Type, quoted bool) encoderFunc {
+	var elemEnc encoderFunc
+	if quoted {
+		elemEnc = newTypeEncoder(t.Elem(), true)
+	} else {
+		elemEnc = typeEncoder(t.Elem())
+	}
+	fallback := fallbackEncoder(t)
+	return func(e *encodeState, p unsafe.Pointer) error {
+		elem := *(*unsafe.Pointer)(p)
+		if elem == nil {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if e.ptrLevel > startDetectingCyclesAfter {
+			return fallback(e, p)
+		}
+		e.ptrLevel++
+		err := elemEnc(e, elem)
+		e.ptrLevel--
+		return err
+	}
+}
+
+func newSliceEncoder(t reflect.Type) encoderFunc {
+	elemEnc := typeEncoder(t.Elem())
+	elemSize := t.Elem().Size()
+	fallback := fallbackEncoder(t)
+	return func(e *encodeState, p unsafe.Pointer) error {
+		sh := (*sliceHeader)(p)
+		if sh.data == nil {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if e.ptrLevel > startDetectingCyclesAfter {
+			return fallback(e, p)
+		}
+		e.ptrLevel++
+		defer func() { e.ptrLevel-- }()
+		e.buf = append(e.buf, '[')
+		for i := 0; i < sh.len; i++ {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			if err := elemEnc(e, unsafe.Add(sh.data, uintptr(i)*elemSize)); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, ']')
+		return nil
+	}
+}
+
+func newArrayEncoder(t reflect.Type) encoderFunc {
+	elemEnc := typeEncoder(t.Elem())
+	elemSize := t.Elem().Size()
+	n := t.Len()
+	return func(e *encodeState, p unsafe.Pointer) error {
+		e.buf = append(e.buf, '[')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			if err := elemEnc(e, unsafe.Add(p, uintptr(i)*elemSize)); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, ']')
+		return nil
+	}
+}
+
+// emptyFunc return the function that reports whether the value is empty for omitempty.
+func emptyFunc(t reflect.Type) func(p unsafe.Pointer) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		n := t.Len()
+		return func(unsafe.Pointer) bool { return n == 0 }
+	case reflect.Slice:
+		return func(p unsafe.Pointer) bool { return (*sliceHeader)(p).len == 0 }
+	case reflect.String:
+		return func(p unsafe.Pointer) bool { return len(*(*string)(p)) == 0 }
+	case reflect.Ptr:
+		return func(p unsafe.Pointer) bool { return *(*unsafe.Pointer)(p) == nil }
+	case reflect.Bool:
+		return func(p unsafe.Pointer) bool { return !*(*bool)(p) }
+	case reflect.Map, reflect.Interface,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return func(p unsafe.Pointer) bool {
+			v := reflect.NewAt(t, p).Elem()
+			switch v.Kind() {
+			case reflect.Map:
+				return v.Len() == 0
+			case reflect.Interface:
+				return v.IsNil()
+			}
+			return v.IsZero()
+		}
+	default:
+		return func(unsafe.Pointer) bool { return false }
+	}
+}
+
+// zeroFunc return the function that reports whether the value is zero for omitzero.
+func zeroFunc(t reflect.Type) func(p unsafe.Pointer) bool {
+	switch {
+	case t.Kind() == reflect.Interface && t.Implements(isZeroerType):
+		return func(p unsafe.Pointer) bool {
+			v := reflect.NewAt(t, p).Elem()
+			return v.IsNil() ||
+				(v.Elem().Kind() == reflect.Ptr && v.Elem().IsNil()) ||
+				v.Interface().(interface{ IsZero() bool }).IsZero()
+		}
+	case t.Kind() == reflect.Ptr && t.Implements(isZeroerType):
+		return func(p unsafe.Pointer) bool {
+			v := reflect.NewAt(t, p).Elem()
+			return v.IsNil() || v.Interface().(interface{ IsZero() bool }).IsZero()
+		}
+	case t.Implements(isZeroerType):
+		return func(p unsafe.Pointer) bool {
+			return reflect.NewAt(t, p).Elem().Interface().(interface{ IsZero() bool }).IsZero()
+		}
+	case reflect.PtrTo(t).Implements(isZeroerType):
+		return func(p unsafe.Pointer) bool {
+			return reflect.NewAt(t, p).Interface().(interface{ IsZero() bool }).IsZero()
+		}
+	default:
+		return func(p unsafe.Pointer) bool {
+			return reflect.NewAt(t, p).Elem().IsZero()
+		}
+	}
+}
+
+const hex = "0123456789abcdef"
+
+// appendString append the JSON string encoding of s as encoding/json does.
+func appendString(dst []byte, s string, escapeHTML bool) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && (!escapeHTML || b != '<' && b != '>' && b != '&') {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch {
+			case b == '\\' || b == '"':
+				dst = append(dst, '\\', b)
+			case b == '\n':
+				dst = append(dst, '\\', 'n')
+			case b == '\r':
+				dst = append(dst, '\\', 'r')
+			case b == '\t':
+				dst = append(dst, '\\', 't')
+			case b == '\b' && escapeBF:
+				dst = append(dst, '\\', 'b')
+			case b == '\f' && escapeBF:
+				dst = append(dst, '\\', 'f')
+			default:
+				// this encodes bytes < 0x20 except for the above, and <, > and &
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, invalidRune...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 is LINE SEPARATOR and U+2029 is PARAGRAPH SEPARATOR,
+		// which are invalid in JSONP
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	dst = append(dst, '"')
+	return dst
+}
@@ -0,0 +1,311 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json_test
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/henrylee2cn/gofield/json"
+)
+
+type (
+	P1 struct {
+		A int
+		b int
+		P2
+	}
+	P2 struct {
+		C int
+		d *int
+		*P3
+	}
+	P3 struct {
+		E int
+		f *int
+		g **int `fe:"target"`
+	}
+	Basic struct {
+		Bool    bool
+		Int     int
+		Int8    int8
+		Int16   int16
+		Int32   int32
+		Int64   int64
+		Uint    uint
+		Uint8   uint8
+		Uint16  uint16
+		Uint32  uint32
+		Uint64  uint64
+		Uintptr uintptr
+		Float32 float32
+		Float64 float64
+		String  string
+		Bytes   []byte
+		Named   Celsius
+	}
+	Celsius float64
+	Tagged  struct {
+		Renamed  string  `json:"renamed"`
+		Ignored  string  `json:"-"`
+		Dash     string  `json:"-,"`
+		Punct    string  `json:"x-y.z"`
+		Empty    string  `json:",omitempty"`
+		IntStr   int     `json:",string"`
+		BoolStr  bool    `json:",string"`
+		FloatStr float64 `json:",string"`
+		StrStr   string  `json:",string"`
+		PtrStr   *int    `json:",string"`
+		NilStr   *int    `json:",string"`
+		SliceStr []int   `json:",string"`
+	}
+	Omit struct {
+		Bool   bool              `json:",omitempty"`
+		Int    int               `json:",omitempty"`
+		Uint   uint              `json:",omitempty"`
+		Float  float64           `json:",omitempty"`
+		String string            `json:",omitempty"`
+		Slice  []int             `json:",omitempty"`
+		Map    map[string]int    `json:",omitempty"`
+		Ptr    *int              `json:",omitempty"`
+		Iface  interface{}       `json:",omitempty"`
+		Array  [0]int            `json:",omitempty"`
+		Struct struct{}          `json:",omitempty"`
+		Time   time.Time         `json:",omitempty"`
+		Zero   time.Time         `json:",omitzero"`
+		ZeroP  *time.Time        `json:",omitzero"`
+		Both   int               `json:",omitempty,omitzero"`
+		Keep   map[string]string `json:",omitempty"`
+	}
+	Embedded struct {
+		Outer string
+		Inner
+		*InnerPtr
+		inner
+		Dup1
+		Dup2
+		Tagged1
+	}
+	Inner struct {
+		A, Shadowed string
+	}
+	InnerPtr struct {
+		B string
+	}
+	inner struct {
+		Hidden  string
+		visible string
+	}
+	Dup1 struct {
+		Same   string
+		Tagged string `json:"tag"`
+	}
+	Dup2 struct {
+		Same string
+		Deep
+	}
+	Deep struct {
+		Tagged string
+	}
+	Tagged1 struct {
+		Shadowed string `json:"Shadowed"`
+	}
+	Container struct {
+		Items   []Item
+		Ptrs    []*Item
+		NilList []Item
+		Empty   []Item
+		Array   [2]Item
+		Matrix  [][]int
+		Map     map[string]Item
+		Iface   interface{}
+		IfaceP  interface{}
+		Any     interface{}
+	}
+	Item struct {
+		Name  string
+		Price *float64 `json:"price,omitempty"`
+	}
+	Node struct {
+		Val  int
+		Next *Node `json:",omitempty"`
+	}
+	// the field promoted through more embedded levels than the default max depth of gofield
+	Lvl01 struct{ Lvl02 }
+	Lvl02 struct{ Lvl03 }
+	Lvl03 struct{ Lvl04 }
+	Lvl04 struct{ Lvl05 }
+	Lvl05 struct{ Lvl06 }
+	Lvl06 struct{ Lvl07 }
+	Lvl07 struct{ Lvl08 }
+	Lvl08 struct{ Lvl09 }
+	Lvl09 struct{ Lvl10 }
+	Lvl10 struct{ Lvl11 }
+	Lvl11 struct{ Lvl12 }
+	Lvl12 struct{ Lvl13 }
+	Lvl13 struct{ Lvl14 }
+	Lvl14 struct{ Lvl15 }
+	Lvl15 struct{ Lvl16 }
+	Lvl16 struct{ Lvl17 }
+	Lvl17 struct{ Leaf int }
+
+	ValueMarshaler struct{ X int }
+	PtrMarshaler   struct{ X int }
+	TextKey        struct{ X int }
+	Marshalers     struct {
+		V   ValueMarshaler
+		VP  *ValueMarshaler
+		P   PtrMarshaler
+		PP  *PtrMarshaler
+		T   TextKey
+		Raw stdjson.RawMessage
+		Num stdjson.Number
+		Dur time.Duration
+		At  time.Time
+	}
+)
+
+func (v ValueMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"value": 1}`), nil
+}
+
+func (p *PtrMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"ptr"`), nil
+}
+
+func (t TextKey) MarshalText() ([]byte, error) {
+	return []byte("text<>"), nil
+}
+
+func goldenCorpus() []interface{} {
+	one, two := 1, 2
+	pone := &one
+	price := 9.5
+	at := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	return []interface{}{
+		&P1{},
+		&P1{A: 1, b: 2, P2: P2{C: 3, d: &one, P3: &P3{E: 5, f: &two, g: &pone}}},
+		&Basic{},
+		&Basic{
+			Bool: true, Int: -1, Int8: math.MinInt8, Int16: math.MaxInt16, Int32: math.MinInt32, Int64: math.MaxInt64,
+			Uint: 1, Uint8: math.MaxUint8, Uint16: math.MaxUint16, Uint32: math.MaxUint32, Uint64: math.MaxUint64,
+			Uintptr: 7, Float32: 3.14, Float64: -1e21, String: "héllo <a&b> \"q\" \\ \n\r\t\b\f\x01    \xff",
+			Bytes: []byte("bytes"), Named: 36.6,
+		},
+		&Basic{Float32: 1e-7, Float64: 1e-7},
+		&Basic{Float32: 1e20, Float64: 123456789.125},
+		&Basic{Float64: math.Copysign(0, -1)},
+		&Tagged{Renamed: "r", Ignored: "i", Dash: "d", Punct: "p", IntStr: 1, BoolStr: true, FloatStr: 1.5, StrStr: `a"b<`, PtrStr: &one, SliceStr: []int{1}},
+		&Omit{},
+		&Omit{Bool: true, Int: 1, Uint: 1, Float: 1, String: "s", Slice: []int{}, Map: map[string]int{"a": 1}, Ptr: &one, Iface: 0, Time: at, Zero: at, ZeroP: &time.Time{}, Both: 1, Keep: map[string]string{}},
+		&Embedded{},
+		&Embedded{Outer: "o", Inner: Inner{A: "a", Shadowed: "s"}, InnerPtr: &InnerPtr{B: "b"}, inner: inner{Hidden: "h", visible: "v"}, Dup1: Dup1{Same: "1", Tagged: "t"}, Dup2: Dup2{Same: "2", Deep: Deep{Tagged: "deep"}}, Tagged1: Tagged1{Shadowed: "t1"}},
+		&Container{},
+		&Container{
+			Items:  []Item{{Name: "a"}, {Name: "b", Price: &price}},
+			Ptrs:   []*Item{nil, {Name: "p"}},
+			Empty:  []Item{},
+			Array:  [2]Item{{Name: "x"}},
+			Matrix: [][]int{{1, 2}, nil, {}},
+			Map:    map[string]Item{"z": {Name: "z"}, "a": {Name: "a"}},
+			Iface:  Item{Name: "i"},
+			IfaceP: &Item{Name: "ip", Price: &price},
+			Any:    map[string]interface{}{"k": []interface{}{1, "x", nil}},
+		},
+		&Node{Val: 1, Next: &Node{Val: 2, Next: &Node{Val: 3}}},
+		&Marshalers{},
+		&Lvl01{},
+		&Marshalers{VP: &ValueMarshaler{}, PP: &PtrMarshaler{}, Raw: stdjson.RawMessage(`[1, 2]`), Num: "12.5", Dur: time.Second, At: at},
+		&struct{}{},
+		[]int{1, 2},
+		map[string]int{"a": 1},
+		"string",
+		nil,
+		Item{Name: "value"},
+	}
+}
+
+func TestMarshalGolden(t *testing.T) {
+	for i, v := range goldenCorpus() {
+		want, wantErr := stdjson.Marshal(v)
+		got, err := json.Marshal(v)
+		assert.Equal(t, wantErr, err, "case %d", i)
+		assert.Equal(t, string(want), string(got), "case %d", i)
+	}
+}
+
+func TestMarshalError(t *testing.T) {
+	_, err := json.Marshal(&Basic{Float64: math.NaN()})
+	assert.EqualError(t, err, "json: unsupported value: NaN")
+	_, err = json.Marshal(&Container{Any: make(chan int)})
+	assert.EqualError(t, err, "json: unsupported type: chan int")
+
+	n := &Node{}
+	n.Next = n
+	_, err = json.Marshal(n)
+	assert.Error(t, err)
+}
+
+func TestEncoder(t *testing.T) {
+	var want, got bytes.Buffer
+	for _, v := range goldenCorpus() {
+		assert.NoError(t, stdjson.NewEncoder(&want).Encode(v))
+		assert.NoError(t, json.NewEncoder(&got).Encode(v))
+	}
+	assert.Equal(t, want.String(), got.String())
+	assert.Equal(t, len(goldenCorpus()), strings.Count(got.String(), "\n"))
+}
+
+func benchValue() *Container {
+	price := 9.5
+	c := &Container{Map: map[string]Item{"k": {Name: "v"}}}
+	for i := 0; i < 16; i++ {
+		c.Items = append(c.Items, Item{Name: "item", Price: &price})
+		c.Ptrs = append(c.Ptrs, &Item{Name: "ptr"})
+	}
+	return c
+}
+
+func BenchmarkMarshal_Gofield(b *testing.B) {
+	b.ReportAllocs()
+	one := 1
+	pone := &one
+	p := &P1{A: 1, P2: P2{C: 3, P3: &P3{E: 5, g: &pone}}}
+	c := benchValue()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = json.Marshal(p)
+		_, _ = json.Marshal(c)
+	}
+}
+
+func BenchmarkMarshal_Std(b *testing.B) {
+	b.ReportAllocs()
+	one := 1
+	pone := &one
+	p := &P1{A: 1, P2: P2{C: 3, P3: &P3{E: 5, g: &pone}}}
+	c := benchValue()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = stdjson.Marshal(p)
+		_, _ = stdjson.Marshal(c)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/henrylee2cn/gofield"
+)
+
+type (
+	// field a JSON object member of the struct, selected by the rules of encoding/json
+	field struct {
+		name      string
+		nameBytes []byte // `"name":` escaped as encoding/json does
+		tagged    bool
+		index     []int
+		typ       reflect.Type // after dereferencing the unnamed pointer type
+		node      *gofield.FieldType
+		// parent the handle of the embedded struct holding the field, nil if the field is not promoted
+		parent    *gofield.Handle
+		omitEmpty bool
+		omitZero  bool
+		quoted    bool
+	}
+	embedded struct {
+		typ      reflect.Type
+		index    []int
+		children []*gofield.FieldType
+	}
+)
+
+// accessor skips the unexported fields as encoding/json does,
+// except the unexported embedded structs whose exported fields are promoted.
+// NOTE:
+//  There is no depth limit as encoding/json, the self-referential types are not expanded anyway
+var accessor = gofield.New(
+	gofield.WithTagKeys("json"),
+	gofield.WithVisibility(gofield.PromotedExported),
+	gofield.WithMaxDeep(math.MaxInt),
+)
+
+// typeFields return the fields that encoding/json encodes for the struct type,
+// in the same order.
+func typeFields(t reflect.Type) []field {
+	st := accessor.MustAnalyze(reflect.New(t))
+	var (
+		current   []embedded
+		next      = []embedded{{typ: t, children: st.FieldTree()}}
+		count     map[reflect.Type]int
+		nextCount map[reflect.Type]int
+		visited   = map[reflect.Type]bool{}
+		fields    []field
+	)
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+			for _, node := range f.children {
+				sf := node.StructField
+				tag, _ := node.TagValue("json")
+				if tag.Name == "-" && len(tag.Options) == 0 {
+					continue
+				}
+				name := tag.Name
+				if !isValidTag(name) {
+					name = ""
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = sf.Index[0]
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				var quoted bool
+				if tag.HasOption("string") {
+					switch ft.Kind() {
+					case reflect.Bool,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64,
+						reflect.String:
+						quoted = true
+					}
+				}
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+					fld := field{
+						name:      name,
+						nameBytes: append(appendString(nil, name, true), ':'),
+						tagged:    tagged,
+						index:     index,
+						typ:       ft,
+						node:      node,
+						omitEmpty: tag.HasOption("omitempty"),
+						omitZero:  supportOmitZero && tag.HasOption("omitzero"),
+						quoted:    quoted,
+					}
+					if parent := node.Parent(); parent != nil {
+						fld.parent, _ = st.Handle(parent.ID())
+					}
+					fields = append(fields, fld)
+					if count[f.typ] > 1 {
+						// annihilate the field of the type embedded multiple times at the same level
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, embedded{typ: ft, index: index, children: node.Children()})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+	// delete the fields hidden by the Go rules for embedded fields,
+	// except that fields with JSON tags are promoted
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != fi.name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+	fields = out
+	sort.Slice(fields, func(i, j int) bool {
+		return indexLess(fields[i].index, fields[j].index)
+	})
+	return fields
+}
+
+// dominantField return the field that is not hidden by the others with the same name.
+// NOTE:
+//  The fields are sorted by depth and tagged first
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+func indexLess(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// backslash and quote chars are reserved, but otherwise any punctuation chars are allowed
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
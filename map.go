@@ -0,0 +1,176 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// MapOptions options of Struct.ToMap and Struct.FromMap
+type MapOptions struct {
+	// Nested create the nested maps mirroring the field tree,
+	// instead of the flat map keyed by selector, e.g. "P2.P3.E"
+	Nested bool
+	// TagKey take the key names from the struct tag, e.g. json or mapstructure;
+	// the fields tagged "-" are ignored, and the field name is used when the tag name is empty
+	TagKey string
+}
+
+// ToMap convert the struct to a map.
+// NOTE:
+//  The struct fields that have an Equal method or no exported fields are values, e.g. time.Time;
+//  No nil pointer fields will be initialized, the unreachable fields are omitted
+func (s *Struct) ToMap(opts MapOptions) map[string]interface{} {
+	m := make(map[string]interface{}, len(s.complete().fields))
+	s.toMap(s.tree.children, "", m, opts)
+	return m
+}
+
+func (s *Struct) toMap(fields []*FieldType, prefix string, m map[string]interface{}, opts MapOptions) {
+	for _, f := range fields {
+		name, ok := mapKeyName(f, opts.TagKey)
+		if !ok {
+			continue
+		}
+		v, ok := s.lookup(f, true)
+		if !ok {
+			continue
+		}
+		if f.isLeaf() {
+			m[prefix+name] = v.elemVal.Interface()
+		} else if opts.Nested {
+			sub := make(map[string]interface{}, len(f.children))
+			s.toMap(f.children, "", sub, opts)
+			m[name] = sub
+		} else {
+			s.toMap(f.children, prefix+name+".", m, opts)
+		}
+	}
+}
+
+// FromMap assign the map values to the struct fields with type conversion.
+// NOTE:
+//  The keys can be flat selectors like "P2.P3.E", or the values can be nested maps;
+//  The unknown keys are ignored;
+//  The fields are assigned in order, and it stops at the first error;
+//  The nil value sets the field itself to zero, e.g. nil pointer;
+//  By the way, the relevant nil pointer fields will be initialized
+func (s *Struct) FromMap(m map[string]interface{}, opts MapOptions) error {
+	s.complete()
+	return s.fromMap(s.tree.children, m, opts)
+}
+
+func (s *Struct) fromMap(fields []*FieldType, m map[string]interface{}, opts MapOptions) error {
+	type entry struct {
+		field *FieldType
+		key   string
+	}
+	entries := make([]entry, 0, len(m))
+	for key := range m {
+		if f := findFieldByKey(fields, key, opts.TagKey); f != nil {
+			entries = append(entries, entry{field: f, key: key})
+		}
+	}
+	// the result does not depend on the map iteration order
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].field.id != entries[j].field.id {
+			return entries[i].field.id < entries[j].field.id
+		}
+		return entries[i].key < entries[j].key
+	})
+	for _, e := range entries {
+		f, val := e.field, m[e.key]
+		if sub, ok := val.(map[string]interface{}); ok && !f.isLeaf() {
+			if err := s.fromMap(f.children, sub, opts); err != nil {
+				return err
+			}
+			continue
+		}
+		if val == nil {
+			s.setZero(f)
+			continue
+		}
+		dst := s.getOrInit(f, true).elemVal
+		src := reflect.ValueOf(val)
+		conv, ok := converterOf(dst.Type(), src.Type())
+		if !ok {
+			return fmt.Errorf("cannot assign %s to %s(%s)", src.Type(), f.selector, dst.Type())
+		}
+		conv(dst, src)
+	}
+	return nil
+}
+
+// setZero set the field itself to zero without initializing the nil pointers.
+func (s *Struct) setZero(f *FieldType) {
+	p, ok := s.lookup(f.parent, false)
+	if !ok {
+		// the field is unreachable, so it is already zero
+		return
+	}
+	raw := f.rawValueAt(unsafe.Pointer(uintptr(p.elemPtr) + f.Offset))
+	raw.Set(reflect.Zero(raw.Type()))
+	if f.ptrNum > 0 {
+		// the cached pointers of the detached structs are stale
+		for i := 1; i < len(s.structPtrs); i++ {
+			s.structPtrs[i] = nil
+		}
+	}
+}
+
+// findFieldByKey find the field by the dot-separated key names.
+func findFieldByKey(fields []*FieldType, key, tagKey string) *FieldType {
+	var f *FieldType
+	for _, seg := range strings.Split(key, ".") {
+		if f != nil && f.opaque {
+			// the subfields of the opaque struct are not keys
+			return nil
+		}
+		f = nil
+		for _, child := range fields {
+			if name, ok := mapKeyName(child, tagKey); ok && name == seg {
+				f = child
+				break
+			}
+		}
+		if f == nil {
+			return nil
+		}
+		fields = f.children
+	}
+	return f
+}
+
+// mapKeyName return the key name of the field, and false if the field is ignored.
+func mapKeyName(f *FieldType, tagKey string) (string, bool) {
+	if tagKey == "" {
+		return f.Name, true
+	}
+	tag, ok := f.TagValue(tagKey)
+	if !ok {
+		return f.Name, true
+	}
+	switch tag.Name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	}
+	return tag.Name, true
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type (
+	// Mapper copy the field values from one struct type to another by a precomputed plan
+	Mapper struct {
+		src, dst  *StructType
+		plan      []mapStep
+		unmatched []*FieldType
+	}
+	mapStep struct {
+		src, dst *FieldType
+		conv     convertFunc
+	}
+	// promotedName the field name at the promotion level
+	promotedName struct {
+		name  string
+		level int
+	}
+)
+
+// MapperTagKey the struct tag key to specify the counterpart field of Mapper,
+// whose value is a selector or the same tag value of the other side.
+// NOTE:
+//  Use `map:"-"` to ignore the field
+const MapperTagKey = "map"
+
+// NewMapper create a mapper that copies src struct to dst struct.
+// NOTE:
+//  Each leaf field of dst is matched with the src field by the tag, the selector or the unique name in turn,
+//  and the struct that has an Equal method or no exported fields is a leaf, e.g. time.Time;
+//  the name matches only the field promoted from the same embedding level, e.g. `.Base.ID` and `.Model.ID`;
+//  The unmatched dst fields can be got by Mapper.Unmatched;
+//  Return error if any matched fields are not convertible, or the conversion may lose information,
+//  e.g. int64 to int32, int to uint and float64 to int
+func NewMapper(src, dst *StructType) (*Mapper, error) {
+	m := &Mapper{src: src, dst: dst}
+	srcFields, dstFields := src.complete().fields, dst.complete().fields
+	var (
+		byKey      = make(map[string]*FieldType, len(srcFields))
+		bySelector = make(map[string]*FieldType, len(srcFields))
+		byName     = make(map[promotedName][]*FieldType, len(srcFields))
+	)
+	for _, f := range srcFields {
+		key, ok := mapperKey(f)
+		if !ok {
+			continue
+		}
+		byKey[key] = f
+		bySelector[f.selector] = f
+		if level, ok := f.promotionLevel(); ok {
+			name := promotedName{name: f.Name, level: level}
+			byName[name] = append(byName[name], f)
+		}
+	}
+	var errs []string
+	for _, f := range dstFields {
+		if !f.isLeaf() {
+			continue
+		}
+		key, ok := mapperKey(f)
+		if !ok {
+			continue
+		}
+		sf := byKey[key]
+		if sf == nil {
+			sf = bySelector[key]
+		}
+		if level, ok := f.promotionLevel(); sf == nil && ok && key == f.selector {
+			if fields := byName[promotedName{name: f.Name, level: level}]; len(fields) == 1 {
+				sf = fields[0]
+			}
+		}
+		if sf == nil {
+			m.unmatched = append(m.unmatched, f)
+			continue
+		}
+		conv, ok := converterOf(f.elemTyp, sf.elemTyp)
+		if !ok || !isLosslessNumber(f.elemTyp, sf.elemTyp) {
+			errs = append(errs, fmt.Sprintf("cannot map %s(%s) to %s(%s)", sf.selector, sf.elemTyp, f.selector, f.elemTyp))
+			continue
+		}
+		m.plan = append(m.plan, mapStep{src: sf, dst: f, conv: conv})
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return m, nil
+}
+
+// isLosslessNumber report whether every srcTyp number can be represented by dstTyp,
+// it is true if either type is not a number.
+func isLosslessNumber(dstTyp, srcTyp reflect.Type) bool {
+	if !isNumberKind(dstTyp.Kind()) || !isNumberKind(srcTyp.Kind()) {
+		return true
+	}
+	dstFloat, srcFloat := isFloatKind(dstTyp.Kind()), isFloatKind(srcTyp.Kind())
+	switch {
+	case dstFloat && srcFloat:
+		return srcTyp.Bits() <= dstTyp.Bits()
+	case srcFloat:
+		return false
+	case dstFloat:
+		// the integer must fit in the mantissa
+		mantissa := 24
+		if dstTyp.Bits() == 64 {
+			mantissa = 53
+		}
+		return srcTyp.Bits() <= mantissa
+	}
+	dstSigned, srcSigned := isSignedKind(dstTyp.Kind()), isSignedKind(srcTyp.Kind())
+	switch {
+	case dstSigned == srcSigned:
+		return srcTyp.Bits() <= dstTyp.Bits()
+	case dstSigned:
+		return srcTyp.Bits() < dstTyp.Bits()
+	default:
+		return false
+	}
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+func isSignedKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+// mapperKey return the normalized tag value or selector of the field,
+// and false if the field is ignored.
+func mapperKey(f *FieldType) (string, bool) {
+	tag, ok := f.Tag.Lookup(MapperTagKey)
+	if !ok || tag == "" {
+		return f.selector, true
+	}
+	if tag == "-" {
+		return "", false
+	}
+	return normalizeSelector(tag), true
+}
+
+// Unmatched return the dst leaf fields that have no counterpart in src.
+func (m *Mapper) Unmatched() []*FieldType {
+	return m.unmatched
+}
+
+// Map copy the field values of src struct pointer to dst struct pointer.
+// NOTE:
+//  The src fields unreachable because of nil pointers are skipped;
+//  The nil pointer fields of dst are initialized only to store the non-zero values
+func (m *Mapper) Map(src, dst interface{}) error {
+	s, err := m.src.Access(src)
+	if err != nil {
+		return err
+	}
+	d, err := m.dst.Access(dst)
+	if err != nil {
+		return err
+	}
+	m.MapStruct(s, d)
+	return nil
+}
+
+// MapStruct copy the field values of src struct accessor to dst struct accessor.
+// NOTE:
+//  src and dst must be accessed through the struct types which the mapper is built from, otherwise panic;
+//  The src fields unreachable because of nil pointers are skipped;
+//  The nil pointer fields of dst are initialized only to store the non-zero values
+func (m *Mapper) MapStruct(src, dst *Struct) {
+	if src.StructType != m.src || dst.StructType != m.dst {
+		panic(errTypeMismatch)
+	}
+	for _, step := range m.plan {
+		v, ok := src.lookup(step.src, true)
+		if !ok {
+			continue
+		}
+		if v.elemVal.IsZero() {
+			// the unreachable dst field is already zero
+			if d, ok := dst.lookup(step.dst, true); ok {
+				step.conv(d.elemVal, v.elemVal)
+			}
+			continue
+		}
+		step.conv(dst.getOrInit(step.dst, true).elemVal, v.elemVal)
+	}
+}
@@ -23,6 +23,8 @@ type (
 	IteratorFunc func(*FieldType) IterPolicy
 	// IterPolicy iteration policy
 	IterPolicy int8
+	// Visibility the fields to be analyzed by their visibility
+	Visibility int8
 )
 
 const (
@@ -40,6 +42,16 @@ const (
 	SkipAndStop
 )
 
+const (
+	// All analyze all fields, including the unexported ones
+	All Visibility = iota
+	// ExportedOnly analyze the exported fields only, and skip the subfields of the unexported fields
+	ExportedOnly
+	// PromotedExported analyze the exported fields, and descend into the unexported embedded structs
+	// whose exported fields are promoted, like encoding/json
+	PromotedExported
+)
+
 // WithGroupBy set GroupByFunc to *Accessor.
 func WithGroupBy(fn GroupByFunc) Option {
 	return func(a *Accessor) {
@@ -54,9 +66,61 @@ func WithIterator(fn IteratorFunc) Option {
 	}
 }
 
+// WithVisibility set the fields to be analyzed by their visibility, default is All.
+// NOTE:
+//  It is applied before IteratorFunc, which does not see the invisible fields
+func WithVisibility(v Visibility) Option {
+	return func(a *Accessor) {
+		a.visibility = v
+	}
+}
+
+// WithCacheSize set the maximum number of the cached struct types, default is 0 for no limit.
+// NOTE:
+//  The least recently used struct type is evicted when the cache is full;
+//  The evicted StructType is still valid, but it is no longer shared by the later accesses
+func WithCacheSize(size int) Option {
+	return func(a *Accessor) {
+		a.cacheSize = size
+	}
+}
+
 // WithMaxDeep set the maximum traversal depth.
+// NOTE:
+//  It limits the nesting depth of each field, the top-level fields are at depth 1
 func WithMaxDeep(maxDeep int) Option {
 	return func(a *Accessor) {
 		a.maxDeep = maxDeep
 	}
 }
+
+// WithLazyAnalysis set whether to analyze the subfields of the nested struct fields on demand,
+// which speeds up the first access to the huge structs.
+// NOTE:
+//  The subfields are analyzed when first reached by the selector, FieldType.Children,
+//  or the methods traversing all fields such as NumField and Struct.Range;
+//  The embedded structs are always analyzed for the promoted names;
+//  The field ids are assigned in the order of analysis, which differs from the eager mode
+func WithLazyAnalysis(lazy bool) Option {
+	return func(a *Accessor) {
+		a.lazy = lazy
+	}
+}
+
+// WithInterfaceDescent set whether Struct.Range and Struct.RangeExisting descend into
+// the dynamic struct pointer held by the interface fields.
+// NOTE:
+//  The dynamic fields are selected like `.Payload.(*Order).ID`, and their ids are relative to the dynamic struct
+func WithInterfaceDescent(descend bool) Option {
+	return func(a *Accessor) {
+		a.descendIface = descend
+	}
+}
+
+// WithTagKeys set the struct tag keys to be parsed and indexed during analysis,
+// which speeds up FieldType.TagValue and StructType.FieldsWithTag for them.
+func WithTagKeys(keys ...string) Option {
+	return func(a *Accessor) {
+		a.tagKeys = append(a.tagKeys, keys...)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"sync"
+)
+
+type (
+	// PlanCache the cache of the plans compiled from the struct types,
+	// e.g. the validation rules of the packages built on gofield, which is keyed by the runtime type ID.
+	// NOTE:
+	//  The zero value is ready to use;
+	//  A plan is compiled for each StructType, since the accessors may analyze the same type differently;
+	//  The plans of the latest few StructTypes of each type are kept, e.g. of several accessors,
+	//  so the plans of the StructTypes evicted from the accessor cache are dropped as the newer ones come
+	PlanCache[P any] struct {
+		mu sync.Mutex
+		m  sync.Map // key is the runtime type ID, value is []planEntry[P] from the latest
+	}
+	planEntry[P any] struct {
+		st   *StructType
+		plan P
+	}
+)
+
+// maxPlansPerType the maximum number of the StructTypes of the same type whose plans are kept
+const maxPlansPerType = 4
+
+// Load return the plan of the struct type, which is compiled by compile on the first call.
+// NOTE:
+//  compile may be called concurrently for the same struct type, and only one of the plans is kept
+func (c *PlanCache[P]) Load(st *StructType, compile func(*StructType) P) P {
+	if plan, ok := c.lookup(st); ok {
+		return plan
+	}
+	plan := compile(st)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.lookup(st); ok {
+		return p
+	}
+	var entries []planEntry[P]
+	if v, ok := c.m.Load(st.tid); ok {
+		entries = v.([]planEntry[P])
+	}
+	if len(entries) >= maxPlansPerType {
+		entries = entries[:maxPlansPerType-1]
+	}
+	c.m.Store(st.tid, append([]planEntry[P]{{st: st, plan: plan}}, entries...))
+	return plan
+}
+
+func (c *PlanCache[P]) lookup(st *StructType) (P, bool) {
+	if v, ok := c.m.Load(st.tid); ok {
+		for _, e := range v.([]planEntry[P]) {
+			if e.st == st {
+				return e.plan, true
+			}
+		}
+	}
+	var zero P
+	return zero, false
+}
+
+// Len return the number of the cached plans.
+func (c *PlanCache[P]) Len() int {
+	var n int
+	c.m.Range(func(_, v interface{}) bool {
+		n += len(v.([]planEntry[P]))
+		return true
+	})
+	return n
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofield
+
+import (
+	"strings"
+)
+
+// TagValue the parsed value of a struct tag key, e.g. `json:"name,omitempty"`
+type TagValue struct {
+	Name    string
+	Options []string
+}
+
+// ParseTagValue parse the struct tag value in the form of `name,opt1,opt2`.
+func ParseTagValue(value string) TagValue {
+	i := strings.IndexByte(value, ',')
+	if i < 0 {
+		return TagValue{Name: value}
+	}
+	return TagValue{Name: value[:i], Options: strings.Split(value[i+1:], ",")}
+}
+
+// HasOption report whether the tag value has the option.
+func (t TagValue) HasOption(opt string) bool {
+	for _, o := range t.Options {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// TagValue get the parsed tag value of the key.
+// NOTE:
+//  The keys registered by WithTagKeys are parsed once during analysis, others are parsed on every call;
+//  Do not modify the returned Options
+func (f *FieldType) TagValue(key string) (TagValue, bool) {
+	if tv, ok := f.tags[key]; ok {
+		if tv == nil {
+			return TagValue{}, false
+		}
+		return *tv, true
+	}
+	value, ok := f.Tag.Lookup(key)
+	if !ok {
+		return TagValue{}, false
+	}
+	return ParseTagValue(value), true
+}
+
+// FieldsWithTag get the fields which have the tag key, in the order of ids.
+// NOTE:
+//  The result of the keys registered by WithTagKeys is indexed during analysis, do not modify it
+func (s *StructType) FieldsWithTag(key string) []*FieldType {
+	all := s.complete().fields
+	if fields, ok := s.tagIndex[key]; ok {
+		return fields
+	}
+	var fields []*FieldType
+	for _, field := range all {
+		if _, ok := field.Tag.Lookup(key); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// parseTags parse the tag values of the registered keys.
+func (f *FieldType) parseTags(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	f.tags = make(map[string]*TagValue, len(keys))
+	for _, key := range keys {
+		var tv *TagValue
+		if value, ok := f.Tag.Lookup(key); ok {
+			v := ParseTagValue(value)
+			tv = &v
+		}
+		f.tags[key] = tv
+	}
+}
+
+func (s *StructType) indexTags(fields []*FieldType, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	s.tagIndex = make(map[string][]*FieldType, len(keys))
+	for _, key := range keys {
+		tagged := make([]*FieldType, 0)
+		for _, field := range fields {
+			if field.tags[key] != nil {
+				tagged = append(tagged, field)
+			}
+		}
+		s.tagIndex[key] = tagged
+	}
+}
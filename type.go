@@ -18,6 +18,8 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/henrylee2cn/ameda"
@@ -26,12 +28,27 @@ import (
 type (
 	// StructType struct type info
 	StructType struct {
-		tid        int32
-		fields     []*FieldType
-		fieldGroup map[string][]*FieldType
-		depth      int
-		tree       *FieldType // id = -1
-		structNum  int
+		acc          *Accessor
+		tid          int32
+		table        atomic.Value // *fieldTable
+		lazy         bool
+		expandMu     sync.Mutex
+		completeOnce sync.Once
+		fieldGroup   map[string][]*FieldType
+		nameIndex    map[string]int          // key is the promoted name, value is field id or -1 if ambiguous
+		tagIndex     map[string][]*FieldType // key is the tag key registered by WithTagKeys
+		tree         *FieldType              // id = -1
+		dynamicViews sync.Map                // key is dynamicKey, value is *StructType
+		accessPool   sync.Pool
+		defaultsOnce sync.Once
+		defaults     *defaultPlan
+	}
+	// fieldTable the analyzed fields, which is replaced as a whole when the lazy struct type expands
+	fieldTable struct {
+		fields        []*FieldType
+		selectorIndex map[string]int // value is field id
+		structNum     int
+		depth         int
 	}
 	// FieldType field type info
 	FieldType struct {
@@ -40,11 +57,24 @@ type (
 		selector string
 		deep     int
 		ptrNum   int
+		cyclic   bool
+		promoted bool
+		opaque   bool // the struct is compared and converted as a whole, see isOpaqueStruct
+		inOpaque bool // any of the ancestors is opaque
+		trimmed  bool // some subfields are removed by the iterator or the max depth
+		// deferred 1 if the subfields are not analyzed yet in the lazy mode
+		deferred uint32
+		owner    *StructType // the struct type that expands the deferred field
 		elemTyp  reflect.Type
-		elemVal  reflectValue
-		rawVal   reflectValue
-		parent   *FieldType
-		children []*FieldType
+		ptrTyp   reflect.Type // pointer to elemTyp
+		// struct type of the slice, array or map elements
+		itemTyp    reflect.Type
+		itemPtrNum int
+		elemVal    reflectValue
+		rawVal     reflectValue
+		parent     *FieldType
+		children   []*FieldType
+		tags       map[string]*TagValue // key is the tag key registered by WithTagKeys, nil value if absent
 		reflect.StructField
 	}
 	reflectValue struct {
@@ -52,8 +82,18 @@ type (
 		ptr  unsafe.Pointer
 		flag uintptr
 	}
+	dynamicKey struct {
+		id  int
+		typ reflect.Type
+	}
+	emptyInterface struct {
+		typ *uintptr
+		ptr unsafe.Pointer
+	}
 )
 
+const flagIndir uintptr = 1 << 7
+
 func newStructType(a *Accessor, tid int32, structPtr interface{}) *StructType {
 	v, ok := structPtr.(reflect.Value)
 	if !ok {
@@ -62,24 +102,47 @@ func newStructType(a *Accessor, tid int32, structPtr interface{}) *StructType {
 	v = ameda.DereferencePtrValue(v)
 	structTyp := v.Type()
 	sTyp := &StructType{
-		tid:    tid,
-		fields: make([]*FieldType, 0, 16),
-		tree:   &FieldType{id: rootID, elemTyp: structTyp},
+		acc:  a,
+		tid:  tid,
+		lazy: a.lazy,
+		tree: &FieldType{id: rootID, elemTyp: structTyp},
+	}
+	t := &fieldTable{
+		fields:        make([]*FieldType, 0, 16),
+		selectorIndex: make(map[string]int, 16),
+		structNum:     1,
 	}
-	var structID int
-	sTyp.traversalFields(&structID, a.maxDeep, a.iterator, sTyp.tree)
-	sTyp.structNum = structID + 1
-	if a.groupBy != nil {
-		sTyp.groupBy(a.groupBy)
+	sTyp.traversalFields(t, sTyp.tree)
+	sTyp.table.Store(t)
+	// the embedded structs are always analyzed, so the promoted names are complete
+	sTyp.indexNames(t.fields)
+	if !sTyp.lazy {
+		sTyp.indexAll(t.fields)
 	}
 	return sTyp
 }
 
-func (s *StructType) traversalFields(structID *int, maxFieldDeep int, iterator IteratorFunc, parent *FieldType) {
-	if s.depth >= maxFieldDeep {
+// indexAll build the indexes that need all fields.
+func (s *StructType) indexAll(fields []*FieldType) {
+	s.indexTags(fields, s.acc.tagKeys)
+	if s.acc.groupBy != nil {
+		s.groupBy(fields, s.acc.groupBy)
+	}
+}
+
+// traversalFields analyze the subfields of parent into the table.
+// NOTE:
+//  In the lazy mode, the subfields of the struct fields are deferred except the embedded ones
+func (s *StructType) traversalFields(t *fieldTable, parent *FieldType) {
+	deep := parent.deep + 1
+	if deep > s.acc.maxDeep {
+		parent.trimmed = true
 		return
 	}
-	s.depth++
+	if deep > t.depth {
+		t.depth = deep
+	}
+	iterator := s.acc.iterator
 	structTyp := parent.elemTyp
 	numField := structTyp.NumField()
 	var structFields []*FieldType
@@ -92,25 +155,39 @@ L:
 			elemTyp = elemTyp.Elem()
 			ptrNum++
 		}
+		if !s.acc.isVisible(f, elemTyp) {
+			continue
+		}
 		_ = reflect.PtrTo(elemTyp)
 		_ = reflect.PtrTo(f.Type)
 		elemVal := reflect.New(elemTyp)
 		rawVal := reflect.New(f.Type)
 		field := &FieldType{
 			parent:      parent,
-			id:          len(s.fields), // 0, 1, 2, ...
+			id:          len(t.fields), // 0, 1, 2, ...
 			selector:    joinFieldName(parent.selector, f.Name),
-			deep:        s.depth,
+			deep:        deep,
 			ptrNum:      ptrNum,
 			elemTyp:     elemTyp,
+			ptrTyp:      elemVal.Type(),
 			elemVal:     *(*reflectValue)(unsafe.Pointer(&elemVal)),
 			rawVal:      *(*reflectValue)(unsafe.Pointer(&rawVal)),
 			StructField: f,
 		}
+		switch elemTyp.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			field.itemTyp, field.itemPtrNum = structItemOf(elemTyp)
+		}
+		field.parseTags(s.acc.tagKeys)
 		isStruct := elemTyp.Kind() == reflect.Struct
+		field.inOpaque = parent.opaque || parent.inOpaque
 		if isStruct {
-			*structID++
-			field.structID = *structID
+			field.opaque = isOpaqueStruct(elemTyp)
+			field.structID = t.structNum
+			t.structNum++
+			// do not expand the back-edge of a self-referential type
+			field.cyclic = parent.hasAncestorType(elemTyp)
+			isStruct = !field.cyclic
 		}
 		if iterator != nil {
 			switch p := iterator(field); p {
@@ -118,44 +195,290 @@ L:
 				fallthrough
 			case Take, TakeAndStop:
 				parent.children = append(parent.children, field)
-				s.fields = append(s.fields, field)
+				t.add(field)
 				if isStruct {
 					structFields = append(structFields, field)
 				}
 				if TakeAndStop == p {
+					parent.trimmed = i < numField-1
 					break L
 				}
 			case SkipOffspring, SkipOffspringAndStop:
 				parent.children = append(parent.children, field)
-				s.fields = append(s.fields, field)
+				t.add(field)
+				field.trimmed = isStruct
 				if SkipOffspringAndStop == p {
+					parent.trimmed = i < numField-1
 					break L
 				}
 			case Skip:
+				parent.trimmed = true
 				continue L
 			case SkipAndStop:
+				parent.trimmed = true
 				break L
 			}
 		} else {
 			parent.children = append(parent.children, field)
-			s.fields = append(s.fields, field)
+			t.add(field)
 			if isStruct {
 				structFields = append(structFields, field)
 			}
 		}
 	}
 	for _, field := range structFields {
-		s.traversalFields(structID, maxFieldDeep, iterator, field)
+		if s.lazy && !field.Anonymous {
+			field.owner = s
+			field.deferred = 1
+			continue
+		}
+		s.traversalFields(t, field)
 	}
 }
 
+func (t *fieldTable) add(field *FieldType) {
+	t.fields = append(t.fields, field)
+	t.selectorIndex[field.selector] = field.id
+}
+
+func (t *fieldTable) clone() *fieldTable {
+	c := *t
+	c.fields = make([]*FieldType, len(t.fields), len(t.fields)*2)
+	copy(c.fields, t.fields)
+	c.selectorIndex = make(map[string]int, len(t.selectorIndex)*2)
+	for k, v := range t.selectorIndex {
+		c.selectorIndex[k] = v
+	}
+	return &c
+}
+
+// loadTable return the fields analyzed so far.
+func (s *StructType) loadTable() *fieldTable {
+	return s.table.Load().(*fieldTable)
+}
+
+// complete analyze all deferred fields in the lazy mode, and return the field table.
+func (s *StructType) complete() *fieldTable {
+	if s.lazy {
+		s.completeOnce.Do(func() {
+			s.expandMu.Lock()
+			defer s.expandMu.Unlock()
+			t := s.loadTable().clone()
+			// the table grows during the loop
+			for i := 0; i < len(t.fields); i++ {
+				if f := t.fields[i]; atomic.LoadUint32(&f.deferred) == 1 {
+					s.traversalFields(t, f)
+				}
+			}
+			s.indexAll(t.fields)
+			s.table.Store(t)
+			for _, f := range t.fields {
+				atomic.StoreUint32(&f.deferred, 0)
+			}
+		})
+	}
+	return s.loadTable()
+}
+
+// expand analyze the subfields of the deferred field in the lazy mode.
+func (s *StructType) expand(f *FieldType) {
+	if atomic.LoadUint32(&f.deferred) == 0 {
+		return
+	}
+	s.expandMu.Lock()
+	defer s.expandMu.Unlock()
+	if atomic.LoadUint32(&f.deferred) == 0 {
+		return
+	}
+	t := s.loadTable().clone()
+	s.traversalFields(t, f)
+	s.table.Store(t)
+	atomic.StoreUint32(&f.deferred, 0)
+}
+
+// expandSelector analyze the deferred field nearest to the selector, and return false if there is none.
+func (s *StructType) expandSelector(t *fieldTable, selector string) bool {
+	for i := len(selector) - 1; i > 0; i-- {
+		if selector[i] != '.' {
+			continue
+		}
+		id, ok := t.selectorIndex[selector[:i]]
+		if !ok {
+			continue
+		}
+		f := t.fields[id]
+		if atomic.LoadUint32(&f.deferred) == 0 {
+			return false
+		}
+		s.expand(f)
+		return true
+	}
+	return false
+}
+
+// isVisible report whether the field is analyzed under the visibility of the accessor.
+func (a *Accessor) isVisible(f reflect.StructField, elemTyp reflect.Type) bool {
+	return a.visibility.allows(f, elemTyp)
+}
+
+// Includes report whether the field and all its ancestors are analyzed under the visibility,
+// e.g. the fields of the other accessors can be checked against PromotedExported like encoding/json.
+func (v Visibility) Includes(f *FieldType) bool {
+	for ; f != nil; f = f.Parent() {
+		if !v.allows(f.StructField, f.elemTyp) {
+			return false
+		}
+	}
+	return true
+}
+
+func (v Visibility) allows(f reflect.StructField, elemTyp reflect.Type) bool {
+	switch v {
+	case ExportedOnly:
+		return f.IsExported()
+	case PromotedExported:
+		return f.IsExported() || (f.Anonymous && elemTyp.Kind() == reflect.Struct)
+	}
+	return true
+}
+
+// structItemOf return the struct type of the container elements and the number of pointers.
+// NOTE:
+//  Return nil if the element is not struct
+func structItemOf(containerTyp reflect.Type) (reflect.Type, int) {
+	itemTyp := containerTyp.Elem()
+	var ptrNum int
+	for itemTyp.Kind() == reflect.Ptr {
+		itemTyp = itemTyp.Elem()
+		ptrNum++
+	}
+	if itemTyp.Kind() != reflect.Struct {
+		return nil, 0
+	}
+	return itemTyp, ptrNum
+}
+
+// hasAncestorType report whether typ is the type of f or one of its ancestors.
+func (f *FieldType) hasAncestorType(typ reflect.Type) bool {
+	for ; f != nil; f = f.parent {
+		if f.elemTyp == typ {
+			return true
+		}
+	}
+	return false
+}
+
 func joinFieldName(parentPath, name string) string {
 	return parentPath + "." + name
 }
 
-func (s *StructType) groupBy(fn GroupByFunc) {
-	s.fieldGroup = make(map[string][]*FieldType, len(s.fields))
-	for _, field := range s.fields {
+// normalizeSelector add the leading dot that joinFieldName produces.
+func normalizeSelector(selector string) string {
+	if len(selector) > 0 && selector[0] == '.' {
+		return selector
+	}
+	return "." + selector
+}
+
+// indexNames index the field names that can be written without the embedded struct names,
+// following the Go selector rules: the shallowest field wins, and the names at the same depth are ambiguous.
+func (s *StructType) indexNames(fields []*FieldType) {
+	type candidate struct {
+		field     *FieldType
+		level     int
+		ambiguous bool
+	}
+	candidates := make(map[string]*candidate, len(fields))
+	for _, field := range fields {
+		level, ok := field.promotionLevel()
+		if !ok {
+			continue
+		}
+		c := candidates[field.Name]
+		switch {
+		case c == nil:
+			candidates[field.Name] = &candidate{field: field, level: level}
+		case level < c.level:
+			*c = candidate{field: field, level: level}
+		case level == c.level:
+			c.ambiguous = true
+		}
+	}
+	s.nameIndex = make(map[string]int, len(candidates))
+	for name, c := range candidates {
+		if c.ambiguous {
+			s.nameIndex[name] = -1
+			continue
+		}
+		s.nameIndex[name] = c.field.id
+		c.field.promoted = c.level > 0
+	}
+}
+
+// isLeaf report whether the field is compared and converted as a whole value,
+// which is an opaque struct, e.g. time.Time, or has no subfields at all.
+// NOTE:
+//  The struct whose subfields are all removed by the iterator or the max depth is not a leaf,
+//  so that the removed subfields are never compared or converted
+func (f *FieldType) isLeaf() bool {
+	return !f.inOpaque && (f.opaque || len(f.children) == 0 && !f.trimmed)
+}
+
+// isOpaqueStruct report whether the struct type is meaningful only as a whole,
+// which has an Equal method or no exported fields, e.g. time.Time.
+func isOpaqueStruct(t reflect.Type) bool {
+	if _, ok := equalMethod(t); ok {
+		return true
+	}
+	return !hasExportedField(t)
+}
+
+// equalMethod return the method `Equal(T) bool` or `Equal(*T) bool` of T or *T.
+func equalMethod(t reflect.Type) (reflect.Method, bool) {
+	m, ok := reflect.PtrTo(t).MethodByName("Equal")
+	if !ok {
+		return m, false
+	}
+	mt := m.Type
+	if mt.NumIn() != 2 || mt.NumOut() != 1 || mt.Out(0).Kind() != reflect.Bool ||
+		(mt.In(1) != t && mt.In(1) != mt.In(0)) {
+		return m, false
+	}
+	return m, true
+}
+
+// hasExportedField report whether the struct type has exported fields,
+// including the ones promoted from the embedded structs.
+func hasExportedField(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.IsExported() {
+			return true
+		}
+		// the embedded struct pointers are not followed, which may be cyclic
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && hasExportedField(f.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// promotionLevel return the number of the embedded structs that the field is nested in,
+// and false if any of its ancestors is not embedded.
+func (f *FieldType) promotionLevel() (int, bool) {
+	var level int
+	for p := f.Parent(); p != nil; p = p.Parent() {
+		if !p.Anonymous {
+			return 0, false
+		}
+		level++
+	}
+	return level, true
+}
+
+func (s *StructType) groupBy(fields []*FieldType, fn GroupByFunc) {
+	s.fieldGroup = make(map[string][]*FieldType, len(fields))
+	for _, field := range fields {
 		group, ok := fn(field)
 		if ok {
 			a := s.fieldGroup[group]
@@ -164,6 +487,59 @@ func (s *StructType) groupBy(fn GroupByFunc) {
 	}
 }
 
+// dynamicView return the type info of the struct pointer held by the interface field,
+// whose selectors are prefixed with the interface field selector and the dynamic type name.
+func (s *StructType) dynamicView(f *FieldType, dynTyp reflect.Type) *StructType {
+	key := dynamicKey{id: f.id, typ: dynTyp}
+	if view, ok := s.dynamicViews.Load(key); ok {
+		return view.(*StructType)
+	}
+	base := s.acc.analyzeType(dynTyp.Elem())
+	bt := base.complete()
+	view := &StructType{
+		acc: s.acc,
+		tid: base.tid,
+	}
+	t := &fieldTable{
+		fields:        make([]*FieldType, len(bt.fields)),
+		selectorIndex: make(map[string]int, len(bt.fields)),
+		structNum:     bt.structNum,
+		depth:         bt.depth,
+	}
+	view.tree = base.tree.cloneTree(nil, f.selector+".("+shortTypeName(dynTyp)+")", f.deep, t.fields)
+	for _, field := range t.fields {
+		t.selectorIndex[field.selector] = field.id
+	}
+	view.table.Store(t)
+	view.indexNames(t.fields)
+	view.indexAll(t.fields)
+	actual, _ := s.dynamicViews.LoadOrStore(key, view)
+	return actual.(*StructType)
+}
+
+func (f *FieldType) cloneTree(parent *FieldType, prefix string, deep int, fields []*FieldType) *FieldType {
+	c := *f
+	c.parent = parent
+	c.selector = prefix + f.selector
+	c.deep += deep
+	if f.id != rootID {
+		fields[f.id] = &c
+	}
+	c.children = make([]*FieldType, len(f.children))
+	for i, child := range f.children {
+		c.children[i] = child.cloneTree(&c, prefix, deep, fields)
+	}
+	return &c
+}
+
+// shortTypeName return the type name without package path, e.g. *Order
+func shortTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		return "*" + shortTypeName(t.Elem())
+	}
+	return t.Name()
+}
+
 // MustAccess create a new struct accessor.
 // NOTE:
 //  If structPtr is not a struct pointer or type mismatch, it will cause panic.
@@ -184,9 +560,55 @@ func (s *StructType) Access(structPtr interface{}) (*Struct, error) {
 	return newStruct(s, ptr), nil
 }
 
+// AccessPointer create a new struct accessor from the struct pointer.
+// NOTE:
+//  The caller guarantees that p points to the struct type, which is not checked
+func (s *StructType) AccessPointer(p unsafe.Pointer) *Struct {
+	return newStruct(s, p)
+}
+
+// AccessPointerWithType create a new struct accessor from the struct pointer,
+// and check that typ is the struct type or the struct pointer type.
+func (s *StructType) AccessPointerWithType(p unsafe.Pointer, typ reflect.Type) (*Struct, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ != s.tree.elemTyp {
+		return nil, errTypeMismatch
+	}
+	return newStruct(s, p), nil
+}
+
+// AcquireAccess get a struct accessor from the pool and bind it to structPtr.
+// NOTE:
+//  Call ReleaseAccess when it is no longer used
+func (s *StructType) AcquireAccess(structPtr interface{}) (*Struct, error) {
+	tid, ptr := parseStructInfo(structPtr)
+	if s.tid != tid {
+		return nil, errTypeMismatch
+	}
+	a, _ := s.accessPool.Get().(*Struct)
+	if a == nil {
+		return newStruct(s, ptr), nil
+	}
+	a.structPtrs[0] = ptr
+	return a, nil
+}
+
+// ReleaseAccess put the struct accessor back to the pool.
+// NOTE:
+//  The accessor must not be used after it is released
+func (s *StructType) ReleaseAccess(a *Struct) {
+	if a == nil || a.StructType != s {
+		return
+	}
+	a.rebind(nil)
+	s.accessPool.Put(a)
+}
+
 // Depth return the struct nesting depth(at least 1).
 func (s *StructType) Depth() int {
-	return s.depth
+	return s.complete().depth
 }
 
 // RuntimeTypeID get the runtime type id of struct.
@@ -195,26 +617,103 @@ func (s *StructType) RuntimeTypeID() int32 {
 }
 
 // NumField get the number of fields.
+// NOTE:
+//  In the lazy mode, all fields are analyzed on the first call
 func (s *StructType) NumField() int {
-	return len(s.fields)
+	return len(s.complete().fields)
+}
+
+// field return the analyzed field corresponding to the id, or nil if the id is invalid.
+func (s *StructType) field(id int) *FieldType {
+	fields := s.loadTable().fields
+	if id < 0 || id >= len(fields) {
+		return nil
+	}
+	return fields[id]
 }
 
 func (s *StructType) checkID(id int) bool {
-	return id >= 0 && id < len(s.fields)
+	return id >= 0 && id < len(s.loadTable().fields)
 }
 
 // FieldType get the field type info corresponding to the id.
 func (s *StructType) FieldType(id int) *FieldType {
-	if !s.checkID(id) {
+	return s.field(id)
+}
+
+// FieldIDBySelector get the field id corresponding to the selector.
+// NOTE:
+//  The leading dot of the selector is optional, e.g. "P2.P3.E" and ".P2.P3.E";
+//  In the lazy mode, the struct fields on the selector path are analyzed
+func (s *StructType) FieldIDBySelector(selector string) (int, error) {
+	normalized := normalizeSelector(selector)
+	t := s.loadTable()
+	id, ok := t.selectorIndex[normalized]
+	for !ok && s.lazy && s.expandSelector(t, normalized) {
+		t = s.loadTable()
+		id, ok = t.selectorIndex[normalized]
+	}
+	if !ok {
+		return 0, fmt.Errorf("selector not found: %s", selector)
+	}
+	return id, nil
+}
+
+// FieldTypeBySelector get the field type info corresponding to the selector.
+// NOTE:
+//  The leading dot of the selector is optional, e.g. "P2.P3.E" and ".P2.P3.E"
+func (s *StructType) FieldTypeBySelector(selector string) (*FieldType, error) {
+	id, err := s.FieldIDBySelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.field(id), nil
+}
+
+// FieldIDByName get the id of the field that can be written by the name in Go code,
+// e.g. "E" for ".P2.P3.E" if P2 and P3 are embedded structs.
+// NOTE:
+//  The shallowest field wins, and the fields of the same name at the same depth are ambiguous
+func (s *StructType) FieldIDByName(name string) (int, error) {
+	id, ok := s.nameIndex[name]
+	if !ok {
+		return 0, fmt.Errorf("name not found: %s", name)
+	}
+	if id < 0 {
+		return 0, fmt.Errorf("ambiguous name: %s", name)
+	}
+	return id, nil
+}
+
+// FieldTypeByName get the field type info that can be written by the name in Go code,
+// e.g. "E" for ".P2.P3.E" if P2 and P3 are embedded structs.
+// NOTE:
+//  The shallowest field wins, and the fields of the same name at the same depth are ambiguous
+func (s *StructType) FieldTypeByName(name string) (*FieldType, error) {
+	id, err := s.FieldIDByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.field(id), nil
+}
+
+// ElemStructType get the struct type info of the slice, array or map elements
+// of the field corresponding to the id.
+// NOTE:
+//  Return nil if the field is not a container of struct or struct pointer
+func (s *StructType) ElemStructType(id int) *StructType {
+	f := s.field(id)
+	if f == nil || f.itemTyp == nil {
 		return nil
 	}
-	return s.fields[id]
+	return s.acc.analyzeType(f.itemTyp)
 }
 
 // Filter filter all fields and return a list of their ids.
 func (s *StructType) Filter(fn func(*FieldType) bool) []int {
-	list := make([]int, 0, s.NumField())
-	for id, field := range s.fields {
+	fields := s.complete().fields
+	list := make([]int, 0, len(fields))
+	for id, field := range fields {
 		if fn(field) {
 			list = append(list, id)
 		}
@@ -224,11 +723,14 @@ func (s *StructType) Filter(fn func(*FieldType) bool) []int {
 
 // GroupTypes return the field types by group.
 func (s *StructType) GroupTypes(group string) []*FieldType {
+	s.complete()
 	a := s.fieldGroup[group]
 	return a
 }
 
 // FieldTree return the field tree.
+// NOTE:
+//  In the lazy mode, the subfields are analyzed on calling FieldType.Children
 func (s *StructType) FieldTree() []*FieldType {
 	return s.tree.children
 }
@@ -269,7 +771,7 @@ func (f *FieldType) dump(prefix string) string {
 		buf.WriteString(fmt.Sprintf("%sid=%d selector=%s\n", prefix, f.id, f.selector))
 		prefix += "····"
 	}
-	for _, child := range f.children {
+	for _, child := range f.Children() {
 		buf.WriteString(child.dump(prefix))
 	}
 	return buf.String()
@@ -290,6 +792,46 @@ func (f *FieldType) UnderlyingKind() reflect.Kind {
 	return f.elemTyp.Kind()
 }
 
+// ElemType get the field type after dereferencing pointers.
+func (f *FieldType) ElemType() reflect.Type {
+	return f.elemTyp
+}
+
+// IsRecursive report whether the field refers to the struct type of itself or of its ancestor.
+// NOTE:
+//  The subfields of a recursive field are not analyzed, use Struct.Descend to access them
+func (f *FieldType) IsRecursive() bool {
+	return f.cyclic
+}
+
+// IsExportedChain report whether the field is exported along the whole selector chain,
+// where the unexported embedded structs are regarded as transparent,
+// e.g. `.Cfg.inner.Port` is exported if `inner` is embedded, but `.cfg.Port` is not.
+// NOTE:
+//  IsExported of reflect.StructField only reports whether the field name itself is exported
+func (f *FieldType) IsExportedChain() bool {
+	if !f.StructField.IsExported() {
+		return false
+	}
+	for p := f.Parent(); p != nil; p = p.Parent() {
+		if !p.StructField.IsExported() && !p.Anonymous {
+			return false
+		}
+	}
+	return true
+}
+
+// Promoted report whether the field is promoted from the embedded structs,
+// that is, it can be written by its name without the embedded struct names in Go code.
+func (f *FieldType) Promoted() bool {
+	return f.promoted
+}
+
+// HasStructElem report whether the field is a slice, array or map of struct or struct pointer.
+func (f *FieldType) HasStructElem() bool {
+	return f.itemTyp != nil
+}
+
 // Parent return the parent field.
 // NOTE:
 //  may return nil
@@ -301,6 +843,11 @@ func (f *FieldType) Parent() *FieldType {
 }
 
 // Children return the child fields.
+// NOTE:
+//  In the lazy mode, the subfields are analyzed on the first call
 func (f *FieldType) Children() []*FieldType {
+	if atomic.LoadUint32(&f.deferred) == 1 {
+		f.owner.expand(f)
+	}
 	return f.children
 }
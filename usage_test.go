@@ -16,7 +16,10 @@ package gofield_test
 
 import (
 	"reflect"
+	"sync"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 
@@ -162,3 +165,1208 @@ func TestGofield4(t *testing.T) {
 	assert.Equal(t, 4, p.C)
 	assert.Equal(t, 5, *p.d)
 }
+
+func TestSelector(t *testing.T) {
+	var p P1
+	s := gofield.MustAccess(&p)
+	for _, selector := range []string{"P2.P3.E", ".P2.P3.E"} {
+		ft, err := s.FieldTypeBySelector(selector)
+		assert.NoError(t, err)
+		assert.Equal(t, ".P2.P3.E", ft.Selector())
+		id, err := s.FieldIDBySelector(selector)
+		assert.NoError(t, err)
+		assert.Equal(t, ft.ID(), id)
+	}
+	v, err := s.FieldValueBySelector("P2.P3.g")
+	assert.NoError(t, err)
+	v.SetInt(3)
+	assert.Equal(t, 3, **p.g)
+
+	_, err = s.FieldTypeBySelector("P2.X")
+	assert.EqualError(t, err, "selector not found: P2.X")
+	v, err = s.FieldValueBySelector("")
+	assert.Error(t, err)
+	assert.False(t, v.IsValid())
+}
+
+func TestLookupValue(t *testing.T) {
+	var p P1
+	p.A = 1
+	s := gofield.MustAccess(&p)
+	v, ok := s.LookupValue(0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), v.Int())
+	id, _ := s.FieldIDBySelector("P2.P3.E")
+	_, ok = s.LookupValue(id)
+	assert.False(t, ok)
+	id, _ = s.FieldIDBySelector("P2.d")
+	_, ok = s.LookupValue(id)
+	assert.False(t, ok)
+	_, ok = s.LookupValue(-1)
+	assert.False(t, ok)
+	var selectors []string
+	s.RangeExisting(func(ft *gofield.FieldType, _ reflect.Value) bool {
+		selectors = append(selectors, ft.Selector())
+		return true
+	})
+	assert.Equal(t, []string{".A", ".b", ".P2", ".P2.C"}, selectors)
+	assert.Nil(t, p.P3)
+	assert.Nil(t, p.d)
+
+	p.P3 = &P3{E: 7}
+	s = gofield.MustAccess(&p)
+	id, _ = s.FieldIDBySelector("P2.P3.E")
+	v, ok = s.LookupValue(id)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), v.Int())
+	id, _ = s.FieldIDBySelector("P2.P3.g")
+	_, ok = s.LookupValue(id)
+	assert.False(t, ok)
+	assert.Nil(t, p.P3.g)
+}
+
+type Node struct {
+	Val  int
+	Next *Node
+}
+
+func TestRecursive(t *testing.T) {
+	var n Node
+	s := gofield.MustAccess(&n)
+	assert.Equal(t, 2, s.NumField())
+	assert.Equal(t, 1, s.Depth())
+	next, err := s.FieldTypeBySelector("Next")
+	assert.NoError(t, err)
+	assert.True(t, next.IsRecursive())
+	assert.Empty(t, next.Children())
+
+	cur := s
+	for i := 1; i <= 3; i++ {
+		cur, err = cur.Descend(next.ID())
+		assert.NoError(t, err)
+		cur.FieldValue(0).SetInt(int64(i))
+	}
+	assert.Equal(t, 1, n.Next.Val)
+	assert.Equal(t, 2, n.Next.Next.Val)
+	assert.Equal(t, 3, n.Next.Next.Next.Val)
+	assert.Nil(t, n.Next.Next.Next.Next)
+
+	_, err = s.Descend(0)
+	assert.Error(t, err)
+	_, err = s.Descend(2)
+	assert.Error(t, err)
+
+	var w struct{ Head Node }
+	s = gofield.MustAccess(&w)
+	assert.Equal(t, 3, s.NumField())
+	id, err := s.FieldIDBySelector("Head.Next")
+	assert.NoError(t, err)
+	cur, err = s.Descend(id)
+	assert.NoError(t, err)
+	cur.FieldValue(0).SetInt(9)
+	assert.Equal(t, 9, w.Head.Next.Val)
+}
+
+func TestMaxDeep(t *testing.T) {
+	type (
+		Twig   struct{ V int }
+		Branch struct{ Twig Twig }
+		Trunk  struct{ A, B, C Branch }
+	)
+	selectors := func(st *gofield.StructType) []string {
+		var a []string
+		for id := 0; id < st.NumField(); id++ {
+			a = append(a, st.FieldType(id).Selector())
+		}
+		return a
+	}
+	st := gofield.New(gofield.WithMaxDeep(2)).MustAnalyze(&Trunk{})
+	// the limit applies to the nesting depth of each field, so the sibling branches are all analyzed;
+	// it used to count the traversed structs, which analyzed `.A.Twig` only and left `.B` and `.C` empty
+	assert.Equal(t, []string{".A", ".B", ".C", ".A.Twig", ".B.Twig", ".C.Twig"}, selectors(st))
+	assert.Equal(t, 2, st.Depth())
+	twig, err := st.FieldTypeBySelector("C.Twig")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, twig.Deep())
+	assert.Empty(t, twig.Children())
+
+	st = gofield.MustAnalyze(&Trunk{})
+	assert.Equal(t, 9, st.NumField())
+	assert.Equal(t, 3, st.Depth())
+	v, _ := st.FieldTypeBySelector("B.Twig.V")
+	assert.Equal(t, 3, v.Deep())
+}
+
+type (
+	Item struct {
+		Name  string
+		Price *int
+	}
+	Order struct {
+		Items []Item
+		Fixed [2]*Item
+		Attrs map[string]*Item
+		Vals  map[int]Item
+	}
+)
+
+func TestRangeElems(t *testing.T) {
+	o := Order{
+		Items: make([]Item, 3),
+		Attrs: map[string]*Item{"a": {Name: "a"}, "b": nil},
+		Vals:  map[int]Item{1: {Name: "v"}},
+	}
+	s := gofield.MustAccess(&o)
+	id, _ := s.FieldIDBySelector("Items")
+	assert.True(t, s.FieldType(id).HasStructElem())
+	assert.Equal(t, 2, s.ElemStructType(id).NumField())
+	var keys []int
+	err := s.RangeElems(id, func(key reflect.Value, elem *gofield.Struct) bool {
+		keys = append(keys, int(key.Int()))
+		elem.FieldValue(1).SetInt(key.Int() * 10)
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2}, keys)
+	assert.Equal(t, 20, *o.Items[2].Price)
+
+	id, _ = s.FieldIDBySelector("Attrs")
+	var names []string
+	err = s.RangeElems(id, func(key reflect.Value, elem *gofield.Struct) bool {
+		names = append(names, elem.FieldValue(0).String())
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, names)
+
+	id, _ = s.FieldIDBySelector("Vals")
+	err = s.RangeElems(id, func(key reflect.Value, elem *gofield.Struct) bool {
+		elem.FieldValue(0).SetString("w")
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "w", o.Vals[1].Name)
+
+	err = s.RangeElems(4, nil)
+	assert.Error(t, err)
+	err = gofield.MustAccess(&P1{}).RangeElems(0, nil)
+	assert.Error(t, err)
+}
+
+func TestElemSelector(t *testing.T) {
+	o := Order{
+		Items: make([]Item, 4),
+		Attrs: map[string]*Item{"k.1": nil},
+		Vals:  map[int]Item{1: {}},
+	}
+	s := gofield.MustAccess(&o)
+	v, err := s.FieldValueBySelector("Items[3].Price")
+	assert.NoError(t, err)
+	v.SetInt(5)
+	assert.Equal(t, 5, *o.Items[3].Price)
+	v, err = s.FieldValueBySelector("Fixed[1].Name")
+	assert.NoError(t, err)
+	v.SetString("f")
+	assert.Equal(t, "f", o.Fixed[1].Name)
+	v, err = s.FieldValueBySelector(`Attrs["k.1"].Name`)
+	assert.NoError(t, err)
+	v.SetString("x")
+	assert.Equal(t, "x", o.Attrs["k.1"].Name)
+	v, err = s.FieldValueBySelector("Items[0]")
+	assert.NoError(t, err)
+	assert.Equal(t, reflect.TypeOf(Item{}), v.Type())
+
+	_, err = s.FieldValueBySelector("Items[4].Price")
+	assert.EqualError(t, err, "index out of range: 4")
+	_, err = s.FieldValueBySelector(`Attrs["x"].Name`)
+	assert.EqualError(t, err, `map key not found: "x"`)
+	_, err = s.FieldValueBySelector("Vals[1].Name")
+	assert.Error(t, err)
+	_, err = s.FieldValueBySelector("Items[1")
+	assert.Error(t, err)
+}
+
+type Envelope struct {
+	Kind    string
+	Payload interface{}
+}
+
+func TestInterfaceDescent(t *testing.T) {
+	accessor := gofield.New(gofield.WithInterfaceDescent(true))
+	e := Envelope{Payload: &Envelope{Kind: "inner", Payload: &Item{Name: "x"}}}
+	s := accessor.MustAccess(&e)
+	var selectors []string
+	s.RangeExisting(func(ft *gofield.FieldType, v reflect.Value) bool {
+		selectors = append(selectors, ft.Selector())
+		return true
+	})
+	assert.Len(t, selectors, 5)
+	selectors = selectors[:0]
+	s.Range(func(ft *gofield.FieldType, v reflect.Value) bool {
+		selectors = append(selectors, ft.Selector())
+		return true
+	})
+	assert.Equal(t, []string{
+		".Kind",
+		".Payload",
+		".Payload.(*Envelope).Kind",
+		".Payload.(*Envelope).Payload",
+		".Payload.(*Envelope).Payload.(*Item).Name",
+		".Payload.(*Envelope).Payload.(*Item).Price",
+	}, selectors)
+	assert.NotNil(t, e.Payload.(*Envelope).Payload.(*Item).Price)
+
+	v, err := s.FieldValueBySelector("Payload.(*Envelope).Payload.(*gofield_test.Item).Name")
+	assert.NoError(t, err)
+	v.SetString("y")
+	assert.Equal(t, "y", e.Payload.(*Envelope).Payload.(*Item).Name)
+	v, err = s.FieldValueBySelector("Payload.(*Envelope)")
+	assert.NoError(t, err)
+	assert.Equal(t, "inner", v.Field(0).String())
+	_, err = s.FieldValueBySelector("Payload.(*Item).Name")
+	assert.EqualError(t, err, "type assertion failed: Payload.(*Item)")
+
+	e.Payload = Item{}
+	_, err = s.FieldValueBySelector("Payload.(*Item).Name")
+	assert.Error(t, err)
+
+	selectors = selectors[:0]
+	gofield.MustAccess(&e).Range(func(ft *gofield.FieldType, v reflect.Value) bool {
+		selectors = append(selectors, ft.Selector())
+		return true
+	})
+	assert.Equal(t, []string{".Kind", ".Payload"}, selectors)
+}
+
+func TestGeneric(t *testing.T) {
+	var p P1
+	s := gofield.MustAccess(&p)
+	id, _ := s.FieldIDBySelector("P2.P3.g")
+	assert.NoError(t, gofield.Set(s, id, 3))
+	assert.Equal(t, 3, **p.g)
+	v, err := gofield.Get[int](s, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+	*gofield.Ptr[int](s, 0) = 1
+	assert.Equal(t, 1, p.A)
+
+	_, err = gofield.Get[string](s, id)
+	assert.EqualError(t, err, "type mismatch")
+	assert.Error(t, gofield.Set(s, -1, 0))
+	assert.Nil(t, gofield.Ptr[*int](s, id))
+	p3 := gofield.Ptr[P3](s, 5)
+	assert.Same(t, p.P3, p3)
+
+	// reading does not initialize the nil pointers
+	var p2 P1
+	s = gofield.MustAccess(&p2)
+	v, err = gofield.Get[int](s, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, v)
+	assert.Nil(t, p2.P3)
+}
+
+func TestTypedField(t *testing.T) {
+	st := gofield.MustAnalyze(&P1{})
+	id, _ := st.FieldIDBySelector("P2.P3.g")
+	g, err := gofield.Field[int](st, id)
+	assert.NoError(t, err)
+	assert.Equal(t, ".P2.P3.g", g.FieldType().Selector())
+	_, err = gofield.Field[*int](st, id)
+	assert.EqualError(t, err, "type mismatch")
+	_, err = gofield.Field[int](st, -1)
+	assert.EqualError(t, err, "field id out of range")
+
+	var p1, p2 P1
+	s1, s2 := st.MustAccess(&p1), st.MustAccess(&p2)
+	assert.Equal(t, 0, g.Get(s1))
+	assert.Nil(t, p1.P3)
+	g.Set(s1, 1)
+	*g.Ptr(s2) = 2
+	assert.Equal(t, 1, **p1.g)
+	assert.Equal(t, 2, g.Get(s2))
+
+	// the struct accessor of another struct type
+	assert.Panics(t, func() {
+		g.Get(gofield.MustAccess(&Node{}))
+	})
+}
+
+func TestHandle(t *testing.T) {
+	st := gofield.MustAnalyze(&P1{})
+	hg, err := st.HandleBySelector("P2.P3.g")
+	assert.NoError(t, err)
+	assert.Equal(t, ".P2.P3.g", hg.FieldType().Selector())
+	hc, err := st.Handle(3)
+	assert.NoError(t, err)
+	assert.Equal(t, ".P2.C", hc.FieldType().Selector())
+	for i := 0; i < 3; i++ {
+		var p P1
+		_, ok := hg.Lookup(unsafe.Pointer(&p))
+		assert.False(t, ok)
+		assert.Nil(t, p.P3)
+		*(*int)(hg.Ptr(unsafe.Pointer(&p))) = i
+		assert.Equal(t, i, **p.g)
+		ptr, ok := hg.Lookup(unsafe.Pointer(&p))
+		assert.True(t, ok)
+		assert.Equal(t, unsafe.Pointer(*p.g), ptr)
+		hc.Value(unsafe.Pointer(&p)).SetInt(int64(i))
+		assert.Equal(t, i, p.C)
+	}
+	_, err = st.Handle(9)
+	assert.Error(t, err)
+	_, err = st.HandleBySelector("X")
+	assert.Error(t, err)
+}
+
+func TestReset(t *testing.T) {
+	var p1, p2 P1
+	s := gofield.MustAccess(&p1)
+	id, _ := s.FieldIDBySelector("P2.P3.E")
+	s.FieldValue(id).SetInt(1)
+	assert.NoError(t, s.Reset(&p2))
+	s.FieldValue(id).SetInt(2)
+	assert.Equal(t, 1, p1.E)
+	assert.Equal(t, 2, p2.E)
+	assert.EqualError(t, s.Reset(&Node{}), "type mismatch")
+
+	st := s.StructType
+	for i := 0; i < 3; i++ {
+		var p P1
+		a, err := st.AcquireAccess(&p)
+		assert.NoError(t, err)
+		a.FieldValue(id).SetInt(int64(i))
+		assert.Equal(t, i, p.E)
+		st.ReleaseAccess(a)
+	}
+	_, err := st.AcquireAccess(&Node{})
+	assert.Error(t, err)
+}
+
+func TestAccessPointer(t *testing.T) {
+	st := gofield.MustAnalyze(&P1{})
+	var p P1
+	s := st.AccessPointer(unsafe.Pointer(&p))
+	s.FieldValue(0).SetInt(1)
+	assert.Equal(t, 1, p.A)
+
+	s, err := st.AccessPointerWithType(unsafe.Pointer(&p), reflect.TypeOf(p))
+	assert.NoError(t, err)
+	s.FieldValue(1).SetInt(2)
+	assert.Equal(t, 2, p.b)
+	_, err = st.AccessPointerWithType(unsafe.Pointer(&p), reflect.TypeOf(&p))
+	assert.NoError(t, err)
+	_, err = st.AccessPointerWithType(unsafe.Pointer(&p), reflect.TypeOf(P2{}))
+	assert.EqualError(t, err, "type mismatch")
+}
+
+type (
+	UserDTO struct {
+		ID      int32
+		Name    []byte
+		Age     *int8
+		Email   string `map:"Contact.Email"`
+		Comment string `map:"-"`
+		Addr    *struct{ City string }
+	}
+	Contact struct {
+		Email string
+		Phone string
+	}
+	User struct {
+		ID      int64
+		Name    string
+		Age     int
+		Contact *Contact
+		City    string
+		Addr    struct{ City string }
+		Comment string
+	}
+)
+
+func TestMapper(t *testing.T) {
+	m, err := gofield.NewMapper(gofield.MustAnalyze(&UserDTO{}), gofield.MustAnalyze(&User{}))
+	assert.NoError(t, err)
+	var unmatched []string
+	for _, ft := range m.Unmatched() {
+		unmatched = append(unmatched, ft.Selector())
+	}
+	// the name does not match the field nested in the non-embedded struct
+	assert.Equal(t, []string{".City", ".Comment", ".Contact.Phone"}, unmatched)
+
+	age := int8(18)
+	dto := UserDTO{ID: 1, Name: []byte("henry"), Age: &age, Email: "a@b.c", Comment: "x", Addr: &struct{ City string }{"bj"}}
+	var u User
+	assert.NoError(t, m.Map(&dto, &u))
+	assert.Equal(t, User{
+		ID:      1,
+		Name:    "henry",
+		Age:     18,
+		Contact: &Contact{Email: "a@b.c"},
+		Addr:    struct{ City string }{"bj"},
+	}, u)
+
+	// the nil pointers of dst are not initialized for the zero values
+	var u2 User
+	assert.NoError(t, m.Map(&UserDTO{}, &u2))
+	assert.Equal(t, User{}, u2)
+	u2.Contact = &Contact{Email: "x"}
+	assert.NoError(t, m.Map(&UserDTO{}, &u2))
+	assert.Equal(t, User{Contact: &Contact{}}, u2)
+	assert.EqualError(t, m.Map(&u, &dto), "type mismatch")
+	assert.Panics(t, func() {
+		m.MapStruct(gofield.MustAccess(&u), gofield.MustAccess(&dto))
+	})
+	// the struct types must be the ones the mapper is built from, even for the same go type
+	assert.Panics(t, func() {
+		m.MapStruct(gofield.New().MustAccess(&dto), gofield.MustAccess(&u))
+	})
+
+	_, err = gofield.NewMapper(gofield.MustAnalyze(&struct{ Age string }{}), gofield.MustAnalyze(&struct{ Age int }{}))
+	assert.EqualError(t, err, "cannot map .Age(string) to .Age(int)")
+}
+
+func TestMapperConversion(t *testing.T) {
+	type Num struct {
+		I8  int8
+		I32 int32
+		I64 int64
+		U8  uint8
+		U64 uint64
+		F32 float32
+		F64 float64
+	}
+	type (
+		Lossless struct {
+			I8  int16   `map:"I8"`
+			I32 float64 `map:"I32"`
+			U8  int16   `map:"U8"`
+			F32 float64 `map:"F32"`
+		}
+		Lossy struct {
+			I64 int32   `map:"I64"`
+			U64 int64   `map:"U64"`
+			I8  uint8   `map:"I8"`
+			F64 int     `map:"F64"`
+			I32 float32 `map:"I32"`
+			F32 float32 `map:"F64"`
+		}
+	)
+	num := gofield.MustAnalyze(&Num{})
+	m, err := gofield.NewMapper(num, gofield.MustAnalyze(&Lossless{}))
+	assert.NoError(t, err)
+	var l Lossless
+	assert.NoError(t, m.Map(&Num{I8: -1, I32: 1 << 30, U8: 255, F32: 0.5}, &l))
+	assert.Equal(t, Lossless{I8: -1, I32: 1 << 30, U8: 255, F32: 0.5}, l)
+
+	_, err = gofield.NewMapper(num, gofield.MustAnalyze(&Lossy{}))
+	assert.EqualError(t, err, "cannot map .I64(int64) to .I64(int32); cannot map .U64(uint64) to .U64(int64); "+
+		"cannot map .I8(int8) to .I8(uint8); cannot map .F64(float64) to .F64(int); "+
+		"cannot map .I32(int32) to .I32(float32); cannot map .F64(float64) to .F32(float32)")
+}
+
+func TestMapperPromotedName(t *testing.T) {
+	type (
+		Base  struct{ ID int }
+		Model struct{ ID int }
+		Src   struct {
+			Base
+			Info struct{ Name string }
+			At   time.Time
+		}
+		Dst struct {
+			Model
+			Name string
+			At   *time.Time
+		}
+	)
+	m, err := gofield.NewMapper(gofield.MustAnalyze(&Src{}), gofield.MustAnalyze(&Dst{}))
+	assert.NoError(t, err)
+	assert.Len(t, m.Unmatched(), 1)
+	assert.Equal(t, ".Name", m.Unmatched()[0].Selector())
+	var d Dst
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, m.Map(&Src{Base: Base{ID: 1}, At: at}, &d))
+	assert.Equal(t, 1, d.ID)
+	assert.Equal(t, at, *d.At)
+}
+
+func TestDiff(t *testing.T) {
+	st := gofield.MustAnalyze(&P1{})
+	a, b := P1{A: 1}, P1{A: 1}
+	assert.True(t, st.Equal(&a, &b))
+	assert.Empty(t, st.Diff(&a, &b))
+
+	b.A, b.b = 2, 3
+	b.P3 = &P3{}
+	diffs := st.Diff(&a, &b)
+	assert.False(t, st.Equal(&a, &b))
+	var selectors []string
+	for _, d := range diffs {
+		selectors = append(selectors, d.Selector)
+	}
+	assert.Equal(t, []string{".A", ".b", ".P2.P3.E"}, selectors)
+	assert.Equal(t, int64(1), diffs[0].Old.Int())
+	assert.Equal(t, int64(2), diffs[0].New.Int())
+	assert.False(t, diffs[2].Old.IsValid())
+	assert.Equal(t, int64(0), diffs[2].New.Int())
+	assert.Nil(t, a.P3)
+	assert.Nil(t, b.d)
+
+	accessor := gofield.New(gofield.WithIterator(func(ft *gofield.FieldType) gofield.IterPolicy {
+		if ft.Name == "b" || ft.Name == "P3" {
+			return gofield.Skip
+		}
+		return gofield.Take
+	}))
+	st = accessor.MustAnalyze(&P1{})
+	diffs = st.Diff(&a, &b)
+	assert.Len(t, diffs, 1)
+	assert.Equal(t, ".A", diffs[0].Selector)
+
+	// the struct whose subfields are all skipped is not compared as a whole
+	type AuditMeta struct {
+		UpdatedAt time.Time
+	}
+	type AuditRec struct {
+		Name string
+		Meta AuditMeta
+	}
+	accessor = gofield.New(gofield.WithIterator(func(ft *gofield.FieldType) gofield.IterPolicy {
+		if ft.Name == "UpdatedAt" {
+			return gofield.Skip
+		}
+		return gofield.Take
+	}))
+	rec1, rec2 := AuditRec{Name: "a"}, AuditRec{Name: "a", Meta: AuditMeta{UpdatedAt: time.Now()}}
+	st = accessor.MustAnalyze(&AuditRec{})
+	assert.Empty(t, st.Diff(&rec1, &rec2))
+	assert.True(t, st.Equal(&rec1, &rec2))
+	rec2.Name = "b"
+	assert.Len(t, st.Diff(&rec1, &rec2), 1)
+	// and neither is the one cut off by the max depth
+	st = gofield.New(gofield.WithMaxDeep(1)).MustAnalyze(&AuditRec{})
+	assert.Equal(t, ".Name", st.Diff(&rec1, &rec2)[0].Selector)
+	assert.Len(t, st.Diff(&rec1, &rec2), 1)
+}
+
+func TestDiffOpaque(t *testing.T) {
+	type Event struct {
+		At     time.Time
+		Expire *time.Time
+		Guard  struct{ n int }
+	}
+	st := gofield.MustAnalyze(&Event{})
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	a, b := Event{At: at}, Event{At: at.In(time.FixedZone("UTC+8", 8*3600))}
+	// the same instant in the different locations
+	assert.True(t, st.Equal(&a, &b))
+
+	b.At = at.Add(time.Second)
+	b.Expire = &at
+	b.Guard.n = 1
+	var selectors []string
+	for _, d := range st.Diff(&a, &b) {
+		selectors = append(selectors, d.Selector)
+	}
+	assert.Equal(t, []string{".At", ".Expire", ".Guard"}, selectors)
+	a.Expire = &at
+	a.Guard.n = 1
+	assert.Equal(t, ".At", st.Diff(&a, &b)[0].Selector)
+	assert.Len(t, st.Diff(&a, &b), 1)
+}
+
+type Config struct {
+	Name  string `json:"name"`
+	Port  uint16 `json:"port,omitempty"`
+	Cache *struct {
+		Size int `json:"size"`
+		TTL  int
+	} `json:"cache"`
+	Secret string `json:"-"`
+}
+
+func TestToMap(t *testing.T) {
+	p := P1{A: 1, P2: P2{P3: &P3{E: 3}}}
+	s := gofield.MustAccess(&p)
+	m := s.ToMap(gofield.MapOptions{})
+	assert.Equal(t, map[string]interface{}{
+		"A": 1, "b": 0, "P2.C": 0, "P2.P3.E": 3,
+	}, m)
+	assert.Nil(t, p.d)
+	assert.Nil(t, p.f)
+	m = s.ToMap(gofield.MapOptions{Nested: true})
+	assert.Equal(t, map[string]interface{}{
+		"A": 1, "b": 0, "P2": map[string]interface{}{
+			"C": 0, "P3": map[string]interface{}{"E": 3},
+		},
+	}, m)
+
+	c := Config{Name: "x", Port: 80, Secret: "s"}
+	s = gofield.MustAccess(&c)
+	m = s.ToMap(gofield.MapOptions{TagKey: "json"})
+	assert.Equal(t, map[string]interface{}{"name": "x", "port": uint16(80)}, m)
+	s.FieldValue(2)
+	m = s.ToMap(gofield.MapOptions{TagKey: "json"})
+	assert.Equal(t, map[string]interface{}{"name": "x", "port": uint16(80), "cache.size": 0, "cache.TTL": 0}, m)
+}
+
+func TestFromMap(t *testing.T) {
+	var p P1
+	s := gofield.MustAccess(&p)
+	err := s.FromMap(map[string]interface{}{
+		"A":       int8(1),
+		"P2.d":    float64(2),
+		"P2":      map[string]interface{}{"P3": map[string]interface{}{"g": uint(3)}},
+		"unknown": 4,
+	}, gofield.MapOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, p.A)
+	assert.Equal(t, 2, *p.d)
+	assert.Equal(t, 3, **p.g)
+
+	var c Config
+	s = gofield.MustAccess(&c)
+	err = s.FromMap(map[string]interface{}{
+		"name":       []byte("x"),
+		"port":       8080,
+		"cache.size": 10,
+		"Secret":     "s",
+	}, gofield.MapOptions{TagKey: "json"})
+	assert.NoError(t, err)
+	assert.Equal(t, "x", c.Name)
+	assert.Equal(t, uint16(8080), c.Port)
+	assert.Equal(t, 10, c.Cache.Size)
+	assert.Equal(t, "", c.Secret)
+
+	err = s.FromMap(map[string]interface{}{"name": 1}, gofield.MapOptions{TagKey: "json"})
+	assert.EqualError(t, err, "cannot assign int to .Name(string)")
+	err = s.FromMap(map[string]interface{}{"name": nil}, gofield.MapOptions{TagKey: "json"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", c.Name)
+
+	// the nil value sets the pointer field itself to nil
+	err = s.FromMap(map[string]interface{}{"cache": nil}, gofield.MapOptions{TagKey: "json"})
+	assert.NoError(t, err)
+	assert.Nil(t, c.Cache)
+	err = s.FromMap(map[string]interface{}{"cache.size": nil}, gofield.MapOptions{TagKey: "json"})
+	assert.NoError(t, err)
+	assert.Nil(t, c.Cache)
+	err = s.FromMap(map[string]interface{}{"cache.size": 1}, gofield.MapOptions{TagKey: "json"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.Cache.Size)
+
+	// the fields are assigned in order regardless of the map iteration order
+	for i := 0; i < 10; i++ {
+		c = Config{}
+		err = s.FromMap(map[string]interface{}{"port": "x", "name": "y", "cache.TTL": "z"}, gofield.MapOptions{TagKey: "json"})
+		assert.EqualError(t, err, "cannot assign string to .Port(uint16)")
+		assert.Equal(t, "y", c.Name)
+		assert.Nil(t, c.Cache)
+	}
+}
+
+func TestMapOpaque(t *testing.T) {
+	type Record struct {
+		Name string
+		At   time.Time
+	}
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	e := Record{Name: "x", At: at}
+	s := gofield.MustAccess(&e)
+	assert.Equal(t, map[string]interface{}{"Name": "x", "At": at}, s.ToMap(gofield.MapOptions{}))
+	assert.Equal(t, map[string]interface{}{"Name": "x", "At": at}, s.ToMap(gofield.MapOptions{Nested: true}))
+
+	e = Record{}
+	assert.NoError(t, s.FromMap(map[string]interface{}{"At": at, "At.wall": uint64(1)}, gofield.MapOptions{}))
+	assert.Equal(t, Record{At: at}, e)
+}
+
+func TestAnalyzeReflectValue(t *testing.T) {
+	st1 := gofield.MustAnalyze(reflect.ValueOf(&P1{}))
+	st2 := gofield.MustAnalyze(reflect.ValueOf(&Node{}))
+	assert.NotEqual(t, st1.RuntimeTypeID(), st2.RuntimeTypeID())
+	assert.Equal(t, gofield.MustAnalyze(&Node{}), st2)
+	assert.Equal(t, 2, st2.NumField())
+}
+
+func TestTagValue(t *testing.T) {
+	type T struct {
+		A int `json:"a,omitempty,inline" xml:"-"`
+		B int `json:",string"`
+		C int `xml:"c"`
+		D struct {
+			E int `json:"e"`
+		}
+	}
+	for _, acc := range []*gofield.Accessor{gofield.New(), gofield.New(gofield.WithTagKeys("json", "yaml"))} {
+		st := acc.MustAnalyze(&T{})
+		f, _ := st.FieldTypeBySelector("A")
+		tag, ok := f.TagValue("json")
+		assert.True(t, ok)
+		assert.Equal(t, gofield.TagValue{Name: "a", Options: []string{"omitempty", "inline"}}, tag)
+		assert.True(t, tag.HasOption("inline"))
+		assert.False(t, tag.HasOption("string"))
+		tag, ok = f.TagValue("xml")
+		assert.True(t, ok)
+		assert.Equal(t, "-", tag.Name)
+		_, ok = f.TagValue("yaml")
+		assert.False(t, ok)
+
+		f, _ = st.FieldTypeBySelector("B")
+		tag, _ = f.TagValue("json")
+		assert.Equal(t, "", tag.Name)
+		assert.True(t, tag.HasOption("string"))
+
+		var selectors []string
+		for _, f := range st.FieldsWithTag("json") {
+			selectors = append(selectors, f.Selector())
+		}
+		assert.Equal(t, []string{".A", ".B", ".D.E"}, selectors)
+		assert.Len(t, st.FieldsWithTag("xml"), 2)
+		assert.Len(t, st.FieldsWithTag("yaml"), 0)
+	}
+}
+
+type (
+	ServerConfig struct {
+		Host    string         `default:"localhost"`
+		Port    int            `default:"8080"`
+		Debug   bool           `default:"true"`
+		Ratio   float32        `default:"0.5"`
+		Timeout time.Duration  `default:"1m30s"`
+		Since   time.Time      `default:"2020-01-02T03:04:05Z"`
+		Tags    []string       `default:"a, b,c"`
+		Limits  map[string]int `default:"x:1,y:2"`
+		Retries *int           `default:"3"`
+		Unset   *int
+		TLS     *TLSConfig
+	}
+	TLSConfig struct {
+		Cert string `default:"cert.pem"`
+	}
+)
+
+func TestApplyDefaults(t *testing.T) {
+	st := gofield.MustAnalyze(&ServerConfig{})
+	zero := 0
+	c := ServerConfig{Host: "example.com", Retries: &zero}
+	assert.NoError(t, st.ApplyDefaults(&c))
+	assert.Equal(t, "example.com", c.Host)
+	assert.Equal(t, 8080, c.Port)
+	assert.True(t, c.Debug)
+	assert.Equal(t, float32(0.5), c.Ratio)
+	assert.Equal(t, 90*time.Second, c.Timeout)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), c.Since)
+	assert.Equal(t, []string{"a", "b", "c"}, c.Tags)
+	assert.Equal(t, map[string]int{"x": 1, "y": 2}, c.Limits)
+	assert.Equal(t, 0, *c.Retries)
+	assert.Nil(t, c.Unset)
+	assert.Equal(t, &TLSConfig{Cert: "cert.pem"}, c.TLS)
+
+	var c2 ServerConfig
+	assert.NoError(t, st.MustAccess(&c2).ApplyDefaults())
+	assert.Equal(t, 3, *c2.Retries)
+	c2.Tags[0] = "changed"
+	assert.Equal(t, "a", c.Tags[0])
+
+	assert.EqualError(t, st.ApplyDefaults(&P1{}), "type mismatch")
+
+	type Bad struct {
+		N int `default:"x"`
+	}
+	err := gofield.MustAnalyze(&Bad{}).ApplyDefaults(&Bad{})
+	assert.EqualError(t, err, `.N: cannot parse "x" as int: strconv.ParseInt: parsing "x": invalid syntax`)
+}
+
+func TestElemType(t *testing.T) {
+	type Schedule struct {
+		At    **time.Time
+		Every time.Duration
+		Tags  []*string
+	}
+	st := gofield.MustAnalyze(&Schedule{})
+	at := st.FieldType(0)
+	assert.Equal(t, reflect.TypeOf(time.Time{}), at.ElemType())
+	assert.True(t, gofield.IsTextType(at.ElemType()), "time.Time is parsed as a whole")
+	assert.False(t, gofield.IsTextType(st.FieldType(1).ElemType()))
+	assert.Equal(t, reflect.TypeOf([]*string{}), st.FieldType(2).ElemType())
+}
+
+func TestParseValue(t *testing.T) {
+	v, err := gofield.ParseValue(reflect.TypeOf([2]*uint8{}), "1,2")
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(2), *v.Interface().([2]*uint8)[1])
+	v, err = gofield.ParseValue(reflect.TypeOf([]byte{}), "raw")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("raw"), v.Bytes())
+	v, err = gofield.ParseValue(reflect.TypeOf([]int{}), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{}, v.Interface())
+	_, err = gofield.ParseValue(reflect.TypeOf([1]int{}), "1,2")
+	assert.EqualError(t, err, `cannot parse "1,2" as [1]int: too many elements, the array length is 1`)
+	_, err = gofield.ParseValue(reflect.TypeOf(map[int]bool{}), "1")
+	assert.EqualError(t, err, `cannot parse "1" as map[int]bool: missing ':' in the map entry "1"`)
+	_, err = gofield.ParseValue(reflect.TypeOf(make(chan int)), "1")
+	assert.EqualError(t, err, `cannot parse "1" as chan int: unsupported type`)
+}
+
+func TestFormatValue(t *testing.T) {
+	one := 1
+	for _, c := range []struct {
+		v    interface{}
+		want string
+	}{
+		{[]*int{&one, &one}, "1,1"},
+		{map[string]float32{"b": 0.5, "a": 2}, "a:2,b:0.5"},
+		{3 * time.Second, "3s"},
+		{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "2020-01-02T03:04:05Z"},
+		{[]byte("raw"), "raw"},
+		{(*int)(nil), ""},
+	} {
+		s := gofield.FormatValue(reflect.ValueOf(c.v))
+		assert.Equal(t, c.want, s)
+		if c.want == "" {
+			continue
+		}
+		v, err := gofield.ParseValue(reflect.TypeOf(c.v), s)
+		assert.NoError(t, err)
+		assert.Equal(t, c.v, v.Interface())
+	}
+}
+
+type (
+	Ambiguous struct {
+		X
+		*Y
+		Z int
+	}
+	X struct {
+		N int
+		Z int
+	}
+	Y struct {
+		N int
+		M struct{ Q int }
+	}
+)
+
+func TestFieldTypeByName(t *testing.T) {
+	st := gofield.MustAnalyze(&P1{})
+	for name, selector := range map[string]string{
+		"A":  ".A",
+		"P2": ".P2",
+		"C":  ".P2.C",
+		"P3": ".P2.P3",
+		"E":  ".P2.P3.E",
+		"g":  ".P2.P3.g",
+	} {
+		ft, err := st.FieldTypeByName(name)
+		assert.NoError(t, err)
+		assert.Equal(t, selector, ft.Selector())
+		assert.Equal(t, ft.Parent() != nil, ft.Promoted())
+	}
+
+	st = gofield.MustAnalyze(&Ambiguous{})
+	ft, err := st.FieldTypeByName("Z")
+	assert.NoError(t, err)
+	assert.Equal(t, ".Z", ft.Selector())
+	assert.False(t, ft.Promoted())
+	ft, _ = st.FieldTypeBySelector("X.Z")
+	assert.False(t, ft.Promoted())
+	ft, err = st.FieldTypeByName("M")
+	assert.NoError(t, err)
+	assert.Equal(t, ".Y.M", ft.Selector())
+	assert.True(t, ft.Promoted())
+	_, err = st.FieldTypeByName("N")
+	assert.EqualError(t, err, "ambiguous name: N")
+	_, err = st.FieldIDByName("Q")
+	assert.EqualError(t, err, "name not found: Q")
+}
+
+type (
+	Visible struct {
+		Name string
+		age  int
+		inner
+		Outer  *inner
+		hidden inner
+	}
+	inner struct {
+		ID     int
+		secret string
+	}
+)
+
+func TestVisibility(t *testing.T) {
+	selectors := func(st *gofield.StructType) []string {
+		var a []string
+		for id := 0; id < st.NumField(); id++ {
+			a = append(a, st.FieldType(id).Selector())
+		}
+		return a
+	}
+	st := gofield.MustAnalyze(&Visible{})
+	assert.Equal(t, []string{".Name", ".age", ".inner", ".Outer", ".hidden",
+		".inner.ID", ".inner.secret", ".Outer.ID", ".Outer.secret", ".hidden.ID", ".hidden.secret"}, selectors(st))
+	var exported []string
+	for id := 0; id < st.NumField(); id++ {
+		if ft := st.FieldType(id); ft.IsExportedChain() {
+			exported = append(exported, ft.Selector())
+		}
+	}
+	assert.Equal(t, []string{".Name", ".Outer", ".inner.ID", ".Outer.ID"}, exported)
+	// IsExported of reflect.StructField is not chain-aware
+	hiddenID, _ := st.FieldTypeBySelector("hidden.ID")
+	assert.True(t, hiddenID.IsExported())
+	assert.False(t, hiddenID.IsExportedChain())
+
+	// the fields of the accessor analyzing all fields are checked against the visibility
+	var included []string
+	for id := 0; id < st.NumField(); id++ {
+		if ft := st.FieldType(id); gofield.PromotedExported.Includes(ft) {
+			included = append(included, ft.Selector())
+		}
+	}
+	assert.Equal(t, []string{".Name", ".inner", ".Outer", ".inner.ID", ".Outer.ID"}, included)
+	assert.False(t, gofield.ExportedOnly.Includes(hiddenID))
+	assert.True(t, gofield.All.Includes(hiddenID))
+
+	st = gofield.New(gofield.WithVisibility(gofield.ExportedOnly)).MustAnalyze(&Visible{})
+	assert.Equal(t, []string{".Name", ".Outer", ".Outer.ID"}, selectors(st))
+
+	st = gofield.New(gofield.WithVisibility(gofield.PromotedExported)).MustAnalyze(&Visible{})
+	assert.Equal(t, []string{".Name", ".inner", ".Outer", ".inner.ID", ".Outer.ID"}, selectors(st))
+	var v Visible
+	s := st.MustAccess(&v)
+	s.FieldValue(3).SetInt(7)
+	s.FieldValue(4).SetInt(8)
+	assert.Equal(t, 7, v.ID)
+	assert.Equal(t, 8, v.Outer.ID)
+}
+
+type (
+	LazyRoot struct {
+		A   int
+		Sub *LazySub
+		P2
+		Arr [2]LazySub
+	}
+	LazySub struct {
+		B    int
+		Leaf struct {
+			C *int
+		}
+	}
+)
+
+func TestLazyAnalysis(t *testing.T) {
+	acc := gofield.New(gofield.WithLazyAnalysis(true))
+	var r LazyRoot
+	s := acc.MustAccess(&r)
+	// the embedded structs are analyzed for the promoted names
+	ft, err := s.FieldTypeByName("E")
+	assert.NoError(t, err)
+	assert.Equal(t, ".P2.P3.E", ft.Selector())
+
+	v, err := s.FieldValueBySelector("Sub.Leaf.C")
+	assert.NoError(t, err)
+	v.SetInt(3)
+	assert.Equal(t, 3, *r.Sub.Leaf.C)
+	id, err := s.FieldIDBySelector("Sub.B")
+	assert.NoError(t, err)
+	s.FieldValue(id).SetInt(2)
+	assert.Equal(t, 2, r.Sub.B)
+	_, err = s.FieldIDBySelector("Sub.X.Y")
+	assert.EqualError(t, err, "selector not found: Sub.X.Y")
+
+	// the accessor created before the expansion still works
+	var r2 LazyRoot
+	s2 := acc.MustAccess(&r2)
+	sub, _ := s2.FieldTypeBySelector("Sub")
+	assert.Len(t, sub.Children(), 2)
+
+	eager := gofield.MustAnalyze(&LazyRoot{})
+	assert.Equal(t, eager.NumField(), s.NumField())
+	assert.Equal(t, eager.Depth(), s.Depth())
+	for id := 0; id < eager.NumField(); id++ {
+		_, err := s.FieldIDBySelector(eager.FieldType(id).Selector())
+		assert.NoError(t, err)
+	}
+	var n int
+	s2.Range(func(*gofield.FieldType, reflect.Value) bool {
+		n++
+		return true
+	})
+	assert.Equal(t, eager.NumField(), n)
+}
+
+func TestLazyAnalysisConcurrently(t *testing.T) {
+	acc := gofield.New(gofield.WithLazyAnalysis(true))
+	st := acc.MustAnalyze(&LazyRoot{})
+	selectors := []string{"Sub.Leaf.C", "Sub.B", "Arr", "P2.P3.g", "Sub.Leaf"}
+	done := make(chan bool)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			defer func() { done <- true }()
+			var r LazyRoot
+			s, _ := st.AcquireAccess(&r)
+			defer st.ReleaseAccess(s)
+			for j := range selectors {
+				selector := selectors[(i+j)%len(selectors)]
+				if _, err := s.FieldValueBySelector(selector); err != nil {
+					t.Error(err)
+				}
+			}
+			if i%2 == 0 {
+				st.NumField()
+			}
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+	assert.Equal(t, gofield.MustAnalyze(&LazyRoot{}).NumField(), st.NumField())
+}
+
+func TestCacheSize(t *testing.T) {
+	acc := gofield.New(gofield.WithCacheSize(2))
+	p1 := acc.MustAnalyze(&P1{})
+	acc.MustAnalyze(&Item{})
+	assert.Equal(t, gofield.CacheStats{Size: 2, Misses: 2}, acc.Stats())
+
+	// P1 is used recently, so Item is evicted
+	assert.Same(t, p1, acc.MustAnalyze(&P1{}))
+	acc.MustAccess(&Order{})
+	assert.Equal(t, gofield.CacheStats{Size: 2, Hits: 1, Misses: 3, Evictions: 1}, acc.Stats())
+	assert.Same(t, p1, acc.MustAnalyze(&P1{}))
+	acc.MustAnalyze(&Item{})
+	assert.Equal(t, gofield.CacheStats{Size: 2, Hits: 2, Misses: 4, Evictions: 2}, acc.Stats())
+
+	assert.True(t, acc.Forget(reflect.TypeOf(Item{})))
+	assert.False(t, acc.Forget(reflect.TypeOf(&Item{})), "*Item and Item are the same cache entry")
+	assert.Equal(t, 1, acc.Stats().Size)
+	// the forgotten struct type is analyzed again
+	p1s := acc.MustAnalyze(&P1{})
+	assert.True(t, acc.Forget(reflect.TypeOf(&P1{})))
+	assert.NotSame(t, p1s, acc.MustAnalyze(&P1{}))
+
+	acc.Purge()
+	assert.Equal(t, 0, acc.Stats().Size)
+
+	// the unlimited cache counts the hits too
+	unlimited := gofield.New()
+	for i := 0; i < 3; i++ {
+		unlimited.MustAnalyze(&P1{})
+	}
+	assert.Equal(t, gofield.CacheStats{Size: 1, Hits: 2, Misses: 1}, unlimited.Stats())
+	var p P1
+	s := acc.MustAccess(&p)
+	*s.FieldValue(0).Addr().Interface().(*int) = 1
+	assert.Equal(t, 1, p.A)
+}
+
+func TestPlanCache(t *testing.T) {
+	var (
+		plans    gofield.PlanCache[[]string]
+		compiled int
+	)
+	compile := func(st *gofield.StructType) []string {
+		compiled++
+		var selectors []string
+		for id := 0; id < st.NumField(); id++ {
+			selectors = append(selectors, st.FieldType(id).Selector())
+		}
+		return selectors
+	}
+	st := gofield.MustAnalyze(&Item{})
+	assert.Equal(t, []string{".Name", ".Price"}, plans.Load(st, compile))
+	assert.Equal(t, []string{".Name", ".Price"}, plans.Load(st, compile))
+	assert.Equal(t, 1, compiled)
+
+	// the struct types of the same type from different accessors do not replace each other
+	st2 := gofield.New().MustAnalyze(&Item{})
+	for i := 0; i < 3; i++ {
+		plans.Load(st, compile)
+		plans.Load(st2, compile)
+	}
+	assert.Equal(t, 2, compiled)
+	assert.Equal(t, 2, plans.Len())
+
+	// the plans of the struct types evicted from the accessor cache are dropped
+	acc := gofield.New(gofield.WithCacheSize(1))
+	for i := 0; i < 10; i++ {
+		plans.Load(acc.MustAnalyze(&P1{}), compile)
+		plans.Load(acc.MustAnalyze(&Item{}), compile)
+	}
+	assert.Equal(t, 22, compiled)
+	assert.Equal(t, 8, plans.Len(), "the latest 4 struct types of each type")
+}
+
+func TestAnalyzeConcurrently(t *testing.T) {
+	acc := gofield.New()
+	const n = 16
+	results := make(chan *gofield.StructType, n)
+	start := make(chan bool)
+	for i := 0; i < n; i++ {
+		go func() {
+			<-start
+			var p P1
+			results <- acc.MustAccess(&p).StructType
+		}()
+	}
+	close(start)
+	first := <-results
+	for i := 1; i < n; i++ {
+		// the type is analyzed exactly once
+		assert.Same(t, first, <-results)
+	}
+	stats := acc.Stats()
+	assert.Equal(t, 1, stats.Size)
+	// the callers waiting for the analysis count as misses
+	assert.Equal(t, uint64(n), stats.Hits+stats.Misses)
+
+	// the hits of the goroutines are all counted
+	for i := 0; i < n; i++ {
+		go func() {
+			for j := 0; j < 100; j++ {
+				acc.MustAnalyze(&P1{})
+			}
+			results <- nil
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-results
+	}
+	assert.Equal(t, stats.Hits+n*100, acc.Stats().Hits)
+}
+
+func TestPurgeInFlight(t *testing.T) {
+	for _, purge := range []func(*gofield.Accessor){
+		(*gofield.Accessor).Purge,
+		func(acc *gofield.Accessor) { acc.Forget(reflect.TypeOf(Node{})) },
+	} {
+		started, resume := make(chan bool), make(chan bool)
+		var once sync.Once
+		acc := gofield.New(gofield.WithIterator(func(*gofield.FieldType) gofield.IterPolicy {
+			once.Do(func() {
+				started <- true
+				<-resume
+			})
+			return gofield.Take
+		}))
+		done := make(chan *gofield.StructType)
+		go func() {
+			done <- acc.MustAnalyze(&Node{})
+		}()
+		<-started
+		purge(acc)
+		close(resume)
+		st := <-done
+		assert.Equal(t, 2, st.NumField())
+		// the analysis in flight is not cached
+		assert.Equal(t, 0, acc.Stats().Size)
+		assert.NotSame(t, st, acc.MustAnalyze(&Node{}))
+		assert.Equal(t, 1, acc.Stats().Size)
+	}
+}
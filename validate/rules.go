@@ -0,0 +1,314 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/henrylee2cn/gofield"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	builtinRules = map[string]compileFunc{
+		"len":      compareParam(func(c int) bool { return c == 0 }),
+		"min":      compareParam(func(c int) bool { return c >= 0 }),
+		"max":      compareParam(func(c int) bool { return c <= 0 }),
+		"gt":       compareParam(func(c int) bool { return c > 0 }),
+		"gte":      compareParam(func(c int) bool { return c >= 0 }),
+		"lt":       compareParam(func(c int) bool { return c < 0 }),
+		"lte":      compareParam(func(c int) bool { return c <= 0 }),
+		"eq":       equalParam(true),
+		"ne":       equalParam(false),
+		"oneof":    oneOf,
+		"email":    stringRule(isEmail),
+		"url":      stringRule(isURL),
+		"eqfield":  compareField(func(c int) bool { return c == 0 }),
+		"nefield":  compareField(func(c int) bool { return c != 0 }),
+		"gtfield":  compareField(func(c int) bool { return c > 0 }),
+		"gtefield": compareField(func(c int) bool { return c >= 0 }),
+		"ltfield":  compareField(func(c int) bool { return c < 0 }),
+		"ltefield": compareField(func(c int) bool { return c <= 0 }),
+	}
+)
+
+// compareParam compile the rule that compares the number, or the length of the string(in runes),
+// slice, array and map, with the parameter.
+func compareParam(ok func(c int) bool) compileFunc {
+	return func(_ *gofield.StructType, ft *gofield.FieldType, param string) (RuleFunc, error) {
+		cmp, err := paramComparer(ft.ElemType(), param)
+		if err != nil {
+			return nil, err
+		}
+		return func(fl *FieldLevel) bool {
+			return ok(cmp(fl.Value))
+		}, nil
+	}
+}
+
+// equalParam compile the rule that checks whether the field equals to the parameter,
+// which compares the length for the slice, array and map.
+func equalParam(equal bool) compileFunc {
+	return func(_ *gofield.StructType, ft *gofield.FieldType, param string) (RuleFunc, error) {
+		t := ft.ElemType()
+		switch t.Kind() {
+		case reflect.String:
+			return func(fl *FieldLevel) bool {
+				return (fl.Value.String() == param) == equal
+			}, nil
+		case reflect.Bool:
+			b, err := strconv.ParseBool(param)
+			if err != nil {
+				return nil, err
+			}
+			return func(fl *FieldLevel) bool {
+				return (fl.Value.Bool() == b) == equal
+			}, nil
+		}
+		cmp, err := paramComparer(t, param)
+		if err != nil {
+			return nil, err
+		}
+		return func(fl *FieldLevel) bool {
+			return (cmp(fl.Value) == 0) == equal
+		}, nil
+	}
+}
+
+// paramComparer return the function that compares the value of type t with the parameter.
+func paramComparer(t reflect.Type, param string) (func(v reflect.Value) int, error) {
+	switch t.Kind() {
+	case reflect.String:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) int {
+			return compareInt(int64(utf8.RuneCountInString(v.String())), int64(n))
+		}, nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) int {
+			return compareInt(int64(v.Len()), int64(n))
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		p, err := gofield.ParseValue(t, param)
+		if err != nil {
+			return nil, err
+		}
+		n := p.Int()
+		return func(v reflect.Value) int {
+			return compareInt(v.Int(), n)
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		p, err := gofield.ParseValue(t, param)
+		if err != nil {
+			return nil, err
+		}
+		n := p.Uint()
+		return func(v reflect.Value) int {
+			return compareUint(v.Uint(), n)
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		p, err := gofield.ParseValue(t, param)
+		if err != nil {
+			return nil, err
+		}
+		n := p.Float()
+		return func(v reflect.Value) int {
+			return compareFloat(v.Float(), n)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// oneOf compile the rule that checks whether the field is one of the space-separated parameter values.
+func oneOf(_ *gofield.StructType, ft *gofield.FieldType, param string) (RuleFunc, error) {
+	t := ft.ElemType()
+	options := strings.Fields(param)
+	switch t.Kind() {
+	case reflect.String:
+		return func(fl *FieldLevel) bool {
+			s := fl.Value.String()
+			for _, o := range options {
+				if s == o {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		cmps := make([]func(v reflect.Value) int, len(options))
+		for i, o := range options {
+			cmp, err := paramComparer(t, o)
+			if err != nil {
+				return nil, err
+			}
+			cmps[i] = cmp
+		}
+		return func(fl *FieldLevel) bool {
+			for _, cmp := range cmps {
+				if cmp(fl.Value) == 0 {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// stringRule compile the rule of the string field.
+func stringRule(fn func(s string) bool) compileFunc {
+	return func(_ *gofield.StructType, ft *gofield.FieldType, param string) (RuleFunc, error) {
+		if t := ft.ElemType(); t.Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported type %s", t)
+		}
+		return func(fl *FieldLevel) bool {
+			return fn(fl.Value.String())
+		}, nil
+	}
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Name == "" && addr.Address == s
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "")
+}
+
+// compareField compile the rule that compares the field with the other field of the same type.
+// NOTE:
+//  The parameter is the name of a sibling field, or the selector of any field if it starts with a dot;
+//  The rule fails if the other field is unreachable
+func compareField(ok func(c int) bool) compileFunc {
+	return func(st *gofield.StructType, ft *gofield.FieldType, param string) (RuleFunc, error) {
+		selector := param
+		if !strings.HasPrefix(param, ".") {
+			if parent := ft.Parent(); parent != nil {
+				selector = parent.Selector() + "." + param
+			}
+		}
+		other, err := st.FieldTypeBySelector(selector)
+		if err != nil {
+			return nil, fmt.Errorf("field %s not found", param)
+		}
+		t := ft.ElemType()
+		if ot := other.ElemType(); ot != t {
+			return nil, fmt.Errorf("mismatched types %s and %s", t, ot)
+		}
+		cmp, err := valueComparer(t)
+		if err != nil {
+			return nil, err
+		}
+		id := other.ID()
+		return func(fl *FieldLevel) bool {
+			v, found := fl.Struct.LookupValue(id)
+			return found && ok(cmp(fl.Value, v))
+		}, nil
+	}
+}
+
+// valueComparer return the function that compares two values of type t.
+func valueComparer(t reflect.Type) (func(a, b reflect.Value) int, error) {
+	if t == timeType {
+		// the values may be derived from unexported fields, which can not call Interface()
+		return func(a, b reflect.Value) int {
+			ta, tb := (*time.Time)(unsafe.Pointer(a.UnsafeAddr())), (*time.Time)(unsafe.Pointer(b.UnsafeAddr()))
+			switch {
+			case ta.Before(*tb):
+				return -1
+			case ta.After(*tb):
+				return 1
+			}
+			return 0
+		}, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(a, b reflect.Value) int {
+			if a.Bool() == b.Bool() {
+				return 0
+			}
+			if b.Bool() {
+				return -1
+			}
+			return 1
+		}, nil
+	case reflect.String:
+		return func(a, b reflect.Value) int {
+			return strings.Compare(a.String(), b.String())
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b reflect.Value) int {
+			return compareInt(a.Int(), b.Int())
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(a, b reflect.Value) int {
+			return compareUint(a.Uint(), b.Uint())
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		return func(a, b reflect.Value) int {
+			return compareFloat(a.Float(), b.Float())
+		}, nil
+	}
+	return nil, errors.New("unsupported type " + t.String())
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
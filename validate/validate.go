@@ -0,0 +1,263 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validate validate the struct fields by the rules in the struct tags,
+// such as `validate:"required,min=1,max=64,oneof=a b,email"`.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/henrylee2cn/gofield"
+)
+
+type (
+	// Validator validate the structs by the rules in the struct tags
+	Validator struct {
+		acc    *gofield.Accessor
+		tagKey string
+		rules  map[string]compileFunc
+		rw     sync.RWMutex
+		plans  gofield.PlanCache[*structPlan]
+	}
+	// Option validator option
+	Option func(*Validator)
+	// FieldLevel the field being validated
+	FieldLevel struct {
+		Struct *gofield.Struct
+		Field  *gofield.FieldType
+		// Value the field value after dereferencing pointers
+		Value reflect.Value
+		Param string
+	}
+	// RuleFunc report whether the field is valid
+	RuleFunc func(fl *FieldLevel) bool
+	// FieldError the field that failed on a rule
+	FieldError struct {
+		Selector string
+		Rule     string
+		Param    string
+	}
+	// Errors the field errors of a struct
+	Errors []*FieldError
+
+	// compileFunc check the rule parameter against the field type and return the checker
+	compileFunc func(st *gofield.StructType, ft *gofield.FieldType, param string) (RuleFunc, error)
+	structPlan  struct {
+		fields []fieldPlan
+		err    error
+	}
+	fieldPlan struct {
+		field     *gofield.FieldType
+		required  bool
+		omitEmpty bool
+		rules     []rulePlan
+	}
+	rulePlan struct {
+		name  string
+		param string
+		check RuleFunc
+	}
+)
+
+// DefaultTagKey the default struct tag key of the rules
+const DefaultTagKey = "validate"
+
+var defaultValidator = New()
+
+// New create a validator.
+func New(opt ...Option) *Validator {
+	v := &Validator{
+		tagKey: DefaultTagKey,
+		rules:  make(map[string]compileFunc, len(builtinRules)),
+	}
+	for name, fn := range builtinRules {
+		v.rules[name] = fn
+	}
+	for _, fn := range opt {
+		fn(v)
+	}
+	if v.acc == nil {
+		v.acc = gofield.New(gofield.WithTagKeys(v.tagKey))
+	}
+	return v
+}
+
+// WithAccessor set the accessor which provides the struct type info,
+// so the type cache can be shared with the other users.
+func WithAccessor(acc *gofield.Accessor) Option {
+	return func(v *Validator) {
+		v.acc = acc
+	}
+}
+
+// WithTagKey set the struct tag key of the rules, default is `validate`.
+func WithTagKey(key string) Option {
+	return func(v *Validator) {
+		v.tagKey = key
+	}
+}
+
+// RegisterRule register the custom rule to the default validator.
+func RegisterRule(name string, fn RuleFunc) {
+	defaultValidator.RegisterRule(name, fn)
+}
+
+// Validate validate the struct that structPtr points to by the default validator.
+func Validate(structPtr interface{}) error {
+	return defaultValidator.Validate(structPtr)
+}
+
+// RegisterRule register the custom rule, which replaces the rule with the same name.
+// NOTE:
+//  The rules should be registered before validating, the compiled struct types are not affected;
+//  `required` and `omitempty` can not be replaced
+func (v *Validator) RegisterRule(name string, fn RuleFunc) {
+	v.rw.Lock()
+	defer v.rw.Unlock()
+	v.rules[name] = func(*gofield.StructType, *gofield.FieldType, string) (RuleFunc, error) {
+		return fn, nil
+	}
+}
+
+// Validate validate the struct that structPtr points to.
+// NOTE:
+//  Return Errors if any field is invalid
+func (v *Validator) Validate(structPtr interface{}) error {
+	st, err := v.acc.Analyze(structPtr)
+	if err != nil {
+		return err
+	}
+	s, err := st.AcquireAccess(structPtr)
+	if err != nil {
+		return err
+	}
+	defer st.ReleaseAccess(s)
+	return v.ValidateStruct(s)
+}
+
+// ValidateStruct validate the struct bound to the accessor.
+// NOTE:
+//  No nil pointer fields will be initialized, a nil pointer field only fails on `required`,
+//  and the subfields of a nil struct pointer are not validated;
+//  Return Errors if any field is invalid
+func (v *Validator) ValidateStruct(s *gofield.Struct) error {
+	plan := v.plans.Load(s.StructType, v.compile)
+	if plan.err != nil {
+		return plan.err
+	}
+	var errs Errors
+	fl := FieldLevel{Struct: s}
+	for i := range plan.fields {
+		f := &plan.fields[i]
+		val, ok := s.LookupValue(f.field.ID())
+		if !ok {
+			// the fields under a nil struct pointer are not validated
+			if f.required && isParentReachable(s, f.field) {
+				errs = append(errs, &FieldError{Selector: f.field.Selector(), Rule: "required"})
+			}
+			continue
+		}
+		if f.omitEmpty && val.IsZero() {
+			continue
+		}
+		if f.required && f.field.Kind() != reflect.Ptr && val.IsZero() {
+			errs = append(errs, &FieldError{Selector: f.field.Selector(), Rule: "required"})
+			continue
+		}
+		fl.Field, fl.Value = f.field, val
+		for _, r := range f.rules {
+			fl.Param = r.param
+			if !r.check(&fl) {
+				errs = append(errs, &FieldError{Selector: f.field.Selector(), Rule: r.name, Param: r.param})
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func isParentReachable(s *gofield.Struct, ft *gofield.FieldType) bool {
+	parent := ft.Parent()
+	if parent == nil {
+		return true
+	}
+	_, ok := s.LookupValue(parent.ID())
+	return ok
+}
+
+// compile compile the rules of the struct type.
+func (v *Validator) compile(st *gofield.StructType) *structPlan {
+	v.rw.RLock()
+	defer v.rw.RUnlock()
+	plan := &structPlan{}
+	for _, ft := range st.FieldsWithTag(v.tagKey) {
+		fp := fieldPlan{field: ft}
+		tag := ft.Tag.Get(v.tagKey)
+		if tag == "" || tag == "-" {
+			continue
+		}
+		for _, item := range strings.Split(tag, ",") {
+			name, param := item, ""
+			if i := strings.IndexByte(item, '='); i >= 0 {
+				name, param = item[:i], item[i+1:]
+			}
+			switch name {
+			case "required":
+				fp.required = true
+				continue
+			case "omitempty":
+				fp.omitEmpty = true
+				continue
+			}
+			compile, ok := v.rules[name]
+			if !ok {
+				plan.err = fmt.Errorf("validate: unknown rule %q of %s", name, ft.Selector())
+				return plan
+			}
+			check, err := compile(st, ft, param)
+			if err != nil {
+				plan.err = fmt.Errorf("validate: invalid rule %q of %s: %v", item, ft.Selector(), err)
+				return plan
+			}
+			fp.rules = append(fp.rules, rulePlan{name: name, param: param, check: check})
+		}
+		plan.fields = append(plan.fields, fp)
+	}
+	return plan
+}
+
+// Error implement error interface.
+func (e *FieldError) Error() string {
+	rule := e.Rule
+	if e.Param != "" {
+		rule += "=" + e.Param
+	}
+	return fmt.Sprintf("validate: %s failed on the %q rule", e.Selector, rule)
+}
+
+// Error implement error interface.
+func (e Errors) Error() string {
+	a := make([]string, len(e))
+	for i, fe := range e {
+		a[i] = fe.Error()
+	}
+	return strings.Join(a, "; ")
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 Henry Lee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/henrylee2cn/gofield"
+	"github.com/henrylee2cn/gofield/validate"
+)
+
+type (
+	SignUp struct {
+		Name     string            `validate:"required,min=1,max=8"`
+		Email    string            `validate:"required,email"`
+		Role     string            `validate:"oneof=admin user"`
+		Age      *uint8            `validate:"required,gte=18,lt=130"`
+		Website  string            `validate:"omitempty,url"`
+		Tags     []string          `validate:"max=2"`
+		Labels   map[string]string `validate:"len=1"`
+		Password string            `validate:"required"`
+		Confirm  string            `validate:"eqfield=Password"`
+		Timeout  time.Duration     `validate:"omitempty,min=1s"`
+		Period   *Period
+	}
+	Period struct {
+		Start time.Time `validate:"required"`
+		End   time.Time `validate:"gtfield=Start"`
+		Level uint8     `validate:"ltefield=.Age"`
+	}
+)
+
+func validSignUp() *SignUp {
+	age := uint8(20)
+	return &SignUp{
+		Name:     "henry",
+		Email:    "henry@example.com",
+		Role:     "admin",
+		Age:      &age,
+		Website:  "https://example.com/a",
+		Labels:   map[string]string{"k": "v"},
+		Password: "secret",
+		Confirm:  "secret",
+	}
+}
+
+func selectors(err error) []string {
+	var a []string
+	for _, fe := range err.(validate.Errors) {
+		a = append(a, fe.Selector+" "+fe.Rule)
+	}
+	return a
+}
+
+func TestValidate(t *testing.T) {
+	s := validSignUp()
+	assert.NoError(t, validate.Validate(s))
+	// the nil struct pointer is not validated and not initialized
+	assert.Nil(t, s.Period)
+
+	s.Name = "toolongname"
+	s.Email = "henry <henry@example.com>"
+	s.Role = "root"
+	s.Website = "example.com"
+	s.Tags = []string{"a", "b", "c"}
+	s.Confirm = "other"
+	s.Timeout = time.Millisecond
+	err := validate.Validate(s)
+	assert.Equal(t, []string{".Name max", ".Email email", ".Role oneof", ".Website url", ".Tags max", ".Confirm eqfield", ".Timeout min"}, selectors(err))
+	assert.True(t, strings.HasPrefix(err.Error(), `validate: .Name failed on the "max=8" rule; validate: .Email failed on the "email" rule`))
+
+	s = &SignUp{}
+	assert.Equal(t, []string{".Name required", ".Email required", ".Role oneof", ".Age required", ".Labels len", ".Password required"}, selectors(validate.Validate(s)))
+	assert.Nil(t, s.Age)
+
+	s = validSignUp()
+	*s.Age = 17
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Period = &Period{Start: start, End: start, Level: 21}
+	assert.Equal(t, []string{".Age gte", ".Period.End gtfield", ".Period.Level ltefield"}, selectors(validate.Validate(s)))
+	*s.Age = 30
+	s.Period.End = start.Add(time.Hour)
+	assert.NoError(t, validate.Validate(s))
+	s.Period.Start = time.Time{}
+	assert.Equal(t, []string{".Period.Start required"}, selectors(validate.Validate(s)))
+}
+
+func TestValidateStruct(t *testing.T) {
+	acc := gofield.New(gofield.WithTagKeys("check"))
+	v := validate.New(validate.WithAccessor(acc), validate.WithTagKey("check"))
+	v.RegisterRule("even", func(fl *validate.FieldLevel) bool {
+		return fl.Value.Int()%2 == 0
+	})
+	type T struct {
+		A int `check:"even"`
+		B int `check:"even,gt=0"`
+	}
+	x := T{A: 2, B: 1}
+	err := v.ValidateStruct(acc.MustAccess(&x))
+	assert.EqualError(t, err, `validate: .B failed on the "even" rule`)
+	x.B = 0
+	assert.Equal(t, []string{".B gt"}, selectors(v.Validate(&x)))
+}
+
+func TestCompileError(t *testing.T) {
+	type (
+		Unknown struct {
+			A int `validate:"unknown"`
+		}
+		BadParam struct {
+			A int `validate:"min=x"`
+		}
+		BadType struct {
+			A bool `validate:"email"`
+		}
+		BadField struct {
+			A int `validate:"gtfield=B"`
+		}
+		MismatchedField struct {
+			A int    `validate:"gtfield=B"`
+			B string `validate:"-"`
+		}
+	)
+	assert.EqualError(t, validate.Validate(&Unknown{}), `validate: unknown rule "unknown" of .A`)
+	assert.EqualError(t, validate.Validate(&BadParam{}), `validate: invalid rule "min=x" of .A: cannot parse "x" as int: strconv.ParseInt: parsing "x": invalid syntax`)
+	assert.EqualError(t, validate.Validate(&BadType{}), `validate: invalid rule "email" of .A: unsupported type bool`)
+	assert.EqualError(t, validate.Validate(&BadField{}), `validate: invalid rule "gtfield=B" of .A: field B not found`)
+	assert.EqualError(t, validate.Validate(&MismatchedField{}), `validate: invalid rule "gtfield=B" of .A: mismatched types int and string`)
+	assert.EqualError(t, validate.Validate(MismatchedField{}), "type is not struct pointer")
+}
+
+func BenchmarkValidate(b *testing.B) {
+	b.ReportAllocs()
+	s := validSignUp()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = validate.Validate(s)
+	}
+}
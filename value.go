@@ -15,7 +15,11 @@
 package gofield
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -23,24 +27,34 @@ type (
 	// Struct struct accessor
 	Struct struct {
 		*StructType
-		structPtrs []uintptr // idx is struct id
+		structPtrs []unsafe.Pointer // idx is struct id
 	}
 	// Value field value
 	Value struct {
 		elemVal reflect.Value
-		elemPtr uintptr
+		elemPtr unsafe.Pointer
 	}
 )
 
-func newStruct(typ *StructType, elemPtr uintptr) *Struct {
+func newStruct(typ *StructType, elemPtr unsafe.Pointer) *Struct {
 	s := &Struct{
 		StructType: typ,
-		structPtrs: make([]uintptr, typ.structNum),
+		structPtrs: make([]unsafe.Pointer, typ.loadTable().structNum),
 	}
 	s.structPtrs[0] = elemPtr
 	return s
 }
 
+// Reset bind the accessor to another struct of the same type, and clear the cached pointers.
+func (s *Struct) Reset(structPtr interface{}) error {
+	tid, ptr := parseStructInfo(structPtr)
+	if s.tid != tid {
+		return errTypeMismatch
+	}
+	s.rebind(ptr)
+	return nil
+}
+
 // FieldValue get the field value corresponding to the id.
 // NOTE:
 //  By the way, the relevant nil pointer fields will be initialized
@@ -48,7 +62,7 @@ func (s *Struct) FieldValue(id int) reflect.Value {
 	if !s.checkID(id) {
 		return zero
 	}
-	return s.getOrInit(s.StructType.fields[id], true).elemVal
+	return s.getOrInit(s.StructType.field(id), true).elemVal
 }
 
 // Field get the field type and value corresponding to the id.
@@ -58,19 +72,310 @@ func (s *Struct) Field(id int) (*FieldType, reflect.Value) {
 	if !s.checkID(id) {
 		return nil, zero
 	}
-	t := s.StructType.fields[id]
+	t := s.StructType.field(id)
 	return t, s.getOrInit(t, true).elemVal
 }
 
+// FieldValueBySelector get the field value corresponding to the selector.
+// NOTE:
+//  The elements of slice, array and map can be selected, e.g. `Items[3].Price` and `Attrs["k"].Name`;
+//  By the way, the relevant nil pointer fields will be initialized
+func (s *Struct) FieldValueBySelector(selector string) (reflect.Value, error) {
+	t, err := s.StructType.FieldTypeBySelector(selector)
+	if err == nil {
+		return s.getOrInit(t, true).elemVal, nil
+	}
+	var offset int
+	if prefix := s.tree.selector; prefix != "" && strings.HasPrefix(selector, prefix) {
+		// skip the type assertion in the prefix of the dynamic struct
+		offset = len(prefix)
+	}
+	i := strings.IndexAny(selector[offset:], "[(")
+	if i < 0 {
+		return zero, err
+	}
+	i += offset
+	if selector[i] == '(' {
+		return s.dynamicValueBySelector(selector, i)
+	}
+	t, err = s.StructType.FieldTypeBySelector(selector[:i])
+	if err != nil {
+		return zero, err
+	}
+	elem, rest, err := s.indexElem(t, selector[i:])
+	if err != nil {
+		return zero, err
+	}
+	if rest == "" {
+		return reflect.NewAt(elem.tree.elemTyp, elem.structPtrs[0]).Elem(), nil
+	}
+	return elem.FieldValueBySelector(rest)
+}
+
+// dynamicValueBySelector get the field value of the struct pointer held by an interface field,
+// i is the index of the opening parenthesis of the type assertion, e.g. `Payload.(*Order).ID`.
+func (s *Struct) dynamicValueBySelector(selector string, i int) (reflect.Value, error) {
+	if i == 0 || selector[i-1] != '.' {
+		return zero, fmt.Errorf("selector not found: %s", selector)
+	}
+	t, err := s.StructType.FieldTypeBySelector(selector[:i-1])
+	if err != nil {
+		return zero, err
+	}
+	end := strings.IndexByte(selector[i:], ')')
+	if end < 0 || t.elemTyp.Kind() != reflect.Interface {
+		return zero, fmt.Errorf("selector not found: %s", selector)
+	}
+	end += i
+	elem := s.dynamicStruct(t, s.getOrInit(t, true).elemVal)
+	if elem == nil {
+		return zero, errNotDynStruct
+	}
+	dynTyp := elem.tree.elemTyp
+	if name := selector[i+1 : end]; name != "*"+dynTyp.Name() && name != "*"+dynTyp.String() {
+		return zero, fmt.Errorf("type assertion failed: %s", selector[:end+1])
+	}
+	if end+1 == len(selector) {
+		return reflect.NewAt(dynTyp, elem.structPtrs[0]).Elem(), nil
+	}
+	return elem.FieldValueBySelector(t.selector + ".(*" + dynTyp.Name() + ")" + selector[end+1:])
+}
+
+// dynamicStruct create the accessor of the struct pointer held by the interface field value.
+// NOTE:
+//  Return nil if it does not hold a non-nil struct pointer
+func (s *Struct) dynamicStruct(t *FieldType, v reflect.Value) *Struct {
+	e := v.Elem()
+	if e.Kind() != reflect.Ptr || e.IsNil() || e.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return newStruct(s.StructType.dynamicView(t, e.Type()), unsafe.Pointer(e.Pointer()))
+}
+
+// RangeElems traverse the elements of the slice, array or map field corresponding to the id,
+// and exit the traversal when fn returns false.
+// NOTE:
+//  The element type must be struct or struct pointer, and nil pointer elements are skipped;
+//  key is the index of slice and array, or the key of map;
+//  elem is reused during the traversal, do not hold it after fn returns;
+//  By the way, the relevant nil pointer fields will be initialized
+func (s *Struct) RangeElems(id int, fn func(key reflect.Value, elem *Struct) bool) error {
+	if !s.checkID(id) {
+		return errIllegalID
+	}
+	t := s.StructType.field(id)
+	if t.itemTyp == nil {
+		return errNotContainer
+	}
+	elem := newStruct(s.StructType.acc.analyzeType(t.itemTyp), nil)
+	v := s.getOrInit(t, true).elemVal
+	if v.Kind() == reflect.Map {
+		iter := v.MapRange()
+		for iter.Next() {
+			if t.itemPtrNum == 0 {
+				// the map element is not addressable, so operate on a copy and write it back
+				item := reflect.New(t.itemTyp)
+				item.Elem().Set(iter.Value())
+				elem.rebind(unsafe.Pointer(item.Pointer()))
+				goon := fn(iter.Key(), elem)
+				v.SetMapIndex(iter.Key(), item.Elem())
+				if !goon {
+					return nil
+				}
+				continue
+			}
+			ptr := derefPtr(unsafe.Pointer(iter.Value().Pointer()), t.itemPtrNum-1)
+			if ptr == nil {
+				continue
+			}
+			elem.rebind(ptr)
+			if !fn(iter.Key(), elem) {
+				return nil
+			}
+		}
+		return nil
+	}
+	for i, n := 0, v.Len(); i < n; i++ {
+		ptr := derefPtr(unsafe.Pointer(v.Index(i).UnsafeAddr()), t.itemPtrNum)
+		if ptr == nil {
+			continue
+		}
+		elem.rebind(ptr)
+		if !fn(reflect.ValueOf(i), elem) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// indexElem create the accessor of the element selected by the index expression,
+// and return the rest of the selector.
+// NOTE:
+//  By the way, the relevant nil pointer fields and elements will be initialized
+func (s *Struct) indexElem(t *FieldType, expr string) (*Struct, string, error) {
+	if t.itemTyp == nil {
+		return nil, "", errNotContainer
+	}
+	lit, rest, err := splitIndexExpr(expr)
+	if err != nil {
+		return nil, "", err
+	}
+	v := s.getOrInit(t, true).elemVal
+	var itemPtr reflect.Value
+	if v.Kind() == reflect.Map {
+		if t.itemPtrNum == 0 {
+			return nil, "", errNotAddrElem
+		}
+		key, err := parseMapKey(lit, v.Type().Key())
+		if err != nil {
+			return nil, "", err
+		}
+		item := v.MapIndex(key)
+		if !item.IsValid() {
+			return nil, "", fmt.Errorf("map key not found: %s", lit)
+		}
+		ptr := reflect.New(item.Type())
+		ptr.Elem().Set(item)
+		itemPtr = derefPtrAndInit(ptr, t.itemPtrNum)
+		v.SetMapIndex(key, ptr.Elem())
+	} else {
+		idx, err := strconv.Atoi(lit)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid index: %s", lit)
+		}
+		if idx < 0 || idx >= v.Len() {
+			return nil, "", fmt.Errorf("index out of range: %d", idx)
+		}
+		itemPtr = v.Index(idx).Addr()
+		if t.itemPtrNum > 0 {
+			itemPtr = derefPtrAndInit(itemPtr, t.itemPtrNum)
+		}
+	}
+	elem := newStruct(s.StructType.acc.analyzeType(t.itemTyp), unsafe.Pointer(itemPtr.Pointer()))
+	return elem, rest, nil
+}
+
+// splitIndexExpr split `[3].Price` into `3` and `.Price`, or `["k"].Name` into `"k"` and `.Name`.
+func splitIndexExpr(expr string) (string, string, error) {
+	end := -1
+	if strings.HasPrefix(expr, `["`) {
+		for i := 2; i < len(expr); i++ {
+			if expr[i] == '\\' {
+				i++
+			} else if expr[i] == '"' {
+				if i+1 < len(expr) && expr[i+1] == ']' {
+					end = i + 1
+				}
+				break
+			}
+		}
+	} else {
+		end = strings.IndexByte(expr, ']')
+	}
+	if end < 0 {
+		return "", "", fmt.Errorf("invalid index expression: %s", expr)
+	}
+	return expr[1:end], expr[end+1:], nil
+}
+
+func parseMapKey(lit string, keyTyp reflect.Type) (reflect.Value, error) {
+	switch keyTyp.Kind() {
+	case reflect.String:
+		str, err := strconv.Unquote(lit)
+		if err != nil {
+			return zero, fmt.Errorf("invalid map key: %s", lit)
+		}
+		return reflect.ValueOf(str).Convert(keyTyp), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(lit, 10, keyTyp.Bits())
+		if err != nil {
+			return zero, fmt.Errorf("invalid map key: %s", lit)
+		}
+		return reflect.ValueOf(i).Convert(keyTyp), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(lit, 10, keyTyp.Bits())
+		if err != nil {
+			return zero, fmt.Errorf("invalid map key: %s", lit)
+		}
+		return reflect.ValueOf(u).Convert(keyTyp), nil
+	default:
+		return zero, errors.New("unsupported map key type: " + keyTyp.String())
+	}
+}
+
+// Descend create the accessor of the struct field corresponding to the id,
+// typically used to access the subfields of a recursive field.
+// NOTE:
+//  By the way, the relevant nil pointer fields will be initialized
+func (s *Struct) Descend(id int) (*Struct, error) {
+	if !s.checkID(id) {
+		return nil, errIllegalID
+	}
+	t := s.StructType.field(id)
+	if t.elemTyp.Kind() != reflect.Struct {
+		return nil, errNotStruct
+	}
+	sTyp := s.StructType.acc.analyzeType(t.elemTyp)
+	return newStruct(sTyp, s.getOrInit(t, false).elemPtr), nil
+}
+
 // Range traverse all fields, and exit the traversal when fn returns false.
 // NOTE:
 //  By the way, the relevant nil pointer fields will be initialized
 func (s *Struct) Range(fn func(*FieldType, reflect.Value) bool) {
-	for _, t := range s.fields {
-		if !fn(t, s.getOrInit(t, true).elemVal) {
-			return
+	s.rangeFields(fn)
+}
+
+func (s *Struct) rangeFields(fn func(*FieldType, reflect.Value) bool) bool {
+	for _, t := range s.complete().fields {
+		v := s.getOrInit(t, true).elemVal
+		if !fn(t, v) {
+			return false
+		}
+		if s.acc.descendIface && t.elemTyp.Kind() == reflect.Interface {
+			if elem := s.dynamicStruct(t, v); elem != nil && !elem.rangeFields(fn) {
+				return false
+			}
 		}
 	}
+	return true
+}
+
+// LookupValue get the field value corresponding to the id.
+// NOTE:
+//  No nil pointer fields will be initialized;
+//  Return false if the id is invalid or the field is unreachable because of a nil pointer
+func (s *Struct) LookupValue(id int) (reflect.Value, bool) {
+	if !s.checkID(id) {
+		return zero, false
+	}
+	v, ok := s.lookup(s.StructType.field(id), true)
+	return v.elemVal, ok
+}
+
+// RangeExisting traverse the reachable fields, and exit the traversal when fn returns false.
+// NOTE:
+//  No nil pointer fields will be initialized, the unreachable fields are skipped
+func (s *Struct) RangeExisting(fn func(*FieldType, reflect.Value) bool) {
+	s.rangeExistingFields(fn)
+}
+
+func (s *Struct) rangeExistingFields(fn func(*FieldType, reflect.Value) bool) bool {
+	for _, t := range s.complete().fields {
+		v, ok := s.lookup(t, true)
+		if !ok {
+			continue
+		}
+		if !fn(t, v.elemVal) {
+			return false
+		}
+		if s.acc.descendIface && t.elemTyp.Kind() == reflect.Interface {
+			if elem := s.dynamicStruct(t, v.elemVal); elem != nil && !elem.rangeExistingFields(fn) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // GroupValues return the field values by group.
@@ -95,34 +400,28 @@ func (s *Struct) getOrInit(f *FieldType, needValue bool) Value {
 		return v
 	}
 	if f.structID > 0 {
+		s.growPtrs(f.structID)
 		v.elemPtr = s.structPtrs[f.structID]
-		if v.elemPtr > 0 {
+		if v.elemPtr != nil {
 			if needValue {
-				elemVal := f.elemVal
-				elemVal.ptr = unsafe.Pointer(v.elemPtr)
-				v.elemVal = (*(*reflect.Value)(unsafe.Pointer(&elemVal))).Elem()
-				// v.elemVal = reflect.NewAt(f.elemTyp, unsafe.Pointer(v.elemPtr)).Elem()
+				v.elemVal = f.elemValueAt(v.elemPtr)
 			}
 			return v
 		}
 	}
-	v.elemPtr = s.getOrInit(f.parent, false).elemPtr + f.Offset
+	v.elemPtr = unsafe.Pointer(uintptr(s.getOrInit(f.parent, false).elemPtr) + f.Offset)
 	if f.ptrNum > 0 {
 		rawVal := f.rawVal
-		rawVal.ptr = unsafe.Pointer(v.elemPtr)
+		rawVal.ptr = v.elemPtr
 		valPtr := *(*reflect.Value)(unsafe.Pointer(&rawVal))
 		// valPtr := reflect.NewAt(f.StructField.Type, unsafe.Pointer(v.elemPtr))
 		valPtr = derefPtrAndInit(valPtr, f.ptrNum)
-		v.elemPtr = valPtr.Pointer()
+		v.elemPtr = unsafe.Pointer(valPtr.Pointer())
 		if needValue {
 			v.elemVal = valPtr.Elem()
 		}
 	} else if needValue {
-		elemVal := f.elemVal
-		elemVal.ptr = unsafe.Pointer(v.elemPtr)
-		v.elemVal = (*(*reflect.Value)(unsafe.Pointer(&elemVal))).Elem()
-		// valPtr := reflect.NewAt(f.elemTyp, unsafe.Pointer(v.elemPtr))
-		// v.elemVal = valPtr.Elem()
+		v.elemVal = f.elemValueAt(v.elemPtr)
 	}
 	if f.structID > 0 {
 		s.structPtrs[f.structID] = v.elemPtr
@@ -130,6 +429,85 @@ func (s *Struct) getOrInit(f *FieldType, needValue bool) Value {
 	return v
 }
 
+// NOTE:
+//  No nil pointer fields will be initialized, return false if the field is unreachable
+func (s *Struct) lookup(f *FieldType, needValue bool) (Value, bool) {
+	var v Value
+	if f.parent == nil {
+		v.elemPtr = s.structPtrs[0]
+		return v, true
+	}
+	if f.structID > 0 {
+		s.growPtrs(f.structID)
+		v.elemPtr = s.structPtrs[f.structID]
+		if v.elemPtr != nil {
+			if needValue {
+				v.elemVal = f.elemValueAt(v.elemPtr)
+			}
+			return v, true
+		}
+	}
+	p, ok := s.lookup(f.parent, false)
+	if !ok {
+		return v, false
+	}
+	v.elemPtr = unsafe.Pointer(uintptr(p.elemPtr) + f.Offset)
+	for i := 0; i < f.ptrNum; i++ {
+		v.elemPtr = *(*unsafe.Pointer)(v.elemPtr)
+		if v.elemPtr == nil {
+			return v, false
+		}
+	}
+	if needValue {
+		v.elemVal = f.elemValueAt(v.elemPtr)
+	}
+	if f.structID > 0 {
+		s.structPtrs[f.structID] = v.elemPtr
+	}
+	return v, true
+}
+
+// growPtrs make room for the struct id analyzed after the accessor is created in the lazy mode.
+func (s *Struct) growPtrs(structID int) {
+	if structID < len(s.structPtrs) {
+		return
+	}
+	ptrs := make([]unsafe.Pointer, s.loadTable().structNum)
+	copy(ptrs, s.structPtrs)
+	s.structPtrs = ptrs
+}
+
+// elemValueAt return the addressable field value stored at ptr.
+func (f *FieldType) elemValueAt(ptr unsafe.Pointer) reflect.Value {
+	elemVal := f.elemVal
+	elemVal.ptr = ptr
+	return (*(*reflect.Value)(unsafe.Pointer(&elemVal))).Elem()
+	// return reflect.NewAt(f.elemTyp, ptr).Elem()
+}
+
+// rawValueAt return the addressable field value before dereferencing pointers stored at ptr.
+func (f *FieldType) rawValueAt(ptr unsafe.Pointer) reflect.Value {
+	rawVal := f.rawVal
+	rawVal.ptr = ptr
+	return (*(*reflect.Value)(unsafe.Pointer(&rawVal))).Elem()
+}
+
+// rebind bind the accessor to another struct of the same type.
+func (s *Struct) rebind(elemPtr unsafe.Pointer) {
+	for i := range s.structPtrs {
+		s.structPtrs[i] = nil
+	}
+	s.structPtrs[0] = elemPtr
+}
+
+// derefPtr dereference the pointer numPtr times, return nil if any pointer is nil.
+func derefPtr(ptr unsafe.Pointer, numPtr int) unsafe.Pointer {
+	for ; numPtr > 0 && ptr != nil; numPtr-- {
+		ptr = *(*unsafe.Pointer)(ptr)
+	}
+	return ptr
+}
+
 func derefPtrAndInit(v reflect.Value, numPtr int) reflect.Value {
 	for ; numPtr > 0; numPtr-- {
 		if v.IsNil() {